@@ -0,0 +1,270 @@
+// Package client 是地磅读取服务的 Go 客户端：订阅读数和事件，断线自动重连续传，并封装打印和仪表指令接口。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client 连接一台地磅读取服务，可以在多个goroutine中同时使用
+type Client struct {
+	base       *url.URL
+	token      string
+	httpClient *http.Client
+	topics     []string
+	sse        bool
+	rate       float64
+	since      uint64
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option 是 New 的可选配置
+type Option func(*Client)
+
+// WithToken 设置访问令牌，以 Authorization: Bearer 发送
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient 设置 HTTP 接口和 SSE 使用的 http.Client，默认 http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTopics 只订阅指定主题，如 scale:entry、print:jobs，默认订阅全部
+func WithTopics(topics ...string) Option {
+	return func(c *Client) { c.topics = topics }
+}
+
+// WithSSE 改用 /events（Server-Sent Events）接收消息，适合不允许 WebSocket 的代理环境
+func WithSSE() Option {
+	return func(c *Client) { c.sse = true }
+}
+
+// WithRate 限制每台地磅每秒最多推送几条读数，0 表示不限制
+func WithRate(rate float64) Option {
+	return func(c *Client) { c.rate = rate }
+}
+
+// WithSince 从指定事件序号之后续传，用于调用方自己保存了最后处理的事件序号
+func WithSince(seq uint64) Option {
+	return func(c *Client) { c.since = seq }
+}
+
+// WithBackoff 设置断线重连的最短和最长等待时间，默认 500ms 和 30s
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) { c.minBackoff, c.maxBackoff = min, max }
+}
+
+// New 创建客户端，baseURL 为服务地址，如 http://localhost:9900
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("服务地址必须以 http:// 或 https:// 开头: %s", baseURL)
+	}
+	c := &Client{
+		base:       base,
+		httpClient: http.DefaultClient,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.minBackoff <= 0 {
+		c.minBackoff = 500 * time.Millisecond
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = c.minBackoff
+	}
+	return c, nil
+}
+
+// StatusError 是服务端返回的非成功状态
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.Code)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Message)
+}
+
+// permanent 判断错误是否重试也无法恢复，如令牌无效或权限不足
+func permanent(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && (se.Code == http.StatusUnauthorized || se.Code == http.StatusForbidden)
+}
+
+func (c *Client) endpoint(path string, query url.Values) *url.URL {
+	u := *c.base
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+func (c *Client) authorize(header http.Header) {
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// do 发送请求，2xx 以外的状态返回 StatusError，body 为 JSON 时解码到 out
+func (c *Client) do(req *http.Request, out interface{}) error {
+	c.authorize(req.Header)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if out != nil && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, out); err != nil && ok {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+	if !ok {
+		return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// Weight 是 GET /scales/{id}/weight 返回的当前读数
+type Weight struct {
+	ScaleID    string     `json:"scaleId"`
+	Connected  bool       `json:"connected"`
+	Weight     *float64   `json:"weight,omitempty"` // 还没有收到读数时为 nil
+	Unit       string     `json:"unit,omitempty"`
+	Stable     bool       `json:"stable"`
+	Mode       string     `json:"mode,omitempty"`
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+	AgeMs      *int64     `json:"age,omitempty"`
+}
+
+// ErrNotStable 表示等待期限内没有出现稳定读数，Weight 仍返回最新读数
+var ErrNotStable = errors.New("重量不稳定")
+
+// Weight 查询地磅当前读数
+func (c *Client) Weight(ctx context.Context, scaleID string) (Weight, error) {
+	return c.weight(ctx, scaleID, nil)
+}
+
+// WaitStable 等待地磅出现稳定读数，最长 timeout（服务端上限 1 分钟）；超时返回最新读数和 ErrNotStable
+func (c *Client) WaitStable(ctx context.Context, scaleID string, timeout time.Duration) (Weight, error) {
+	w, err := c.weight(ctx, scaleID, url.Values{"stable": {"true"}, "timeout": {timeout.String()}})
+	if err == nil && !w.Stable {
+		return w, ErrNotStable
+	}
+	return w, err
+}
+
+func (c *Client) weight(ctx context.Context, scaleID string, query url.Values) (Weight, error) {
+	var w Weight
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/scales/"+url.PathEscape(scaleID)+"/weight", query).String(), nil)
+	if err != nil {
+		return w, err
+	}
+	return w, c.do(req, &w)
+}
+
+// CommandResult 是仪表指令的执行结果
+type CommandResult struct {
+	ScaleID   string `json:"scaleId"`
+	Command   string `json:"command"`
+	Confirmed bool   `json:"confirmed"` // 是否通过后续读数确认指令已生效
+	Checkable bool   `json:"checkable"` // 该型号协议能否确认此指令
+	Weight    string `json:"weight,omitempty"`
+}
+
+type commandResponse struct {
+	Success bool          `json:"success"`
+	Result  CommandResult `json:"result"`
+	Message string        `json:"message"`
+}
+
+// SendCommand 向仪表发送 zero、tare 等指令，需要 admin 权限的令牌。
+// 协议无法确认的指令返回 Checkable=false 且没有错误。
+func (c *Client) SendCommand(ctx context.Context, scaleID, command string) (CommandResult, error) {
+	body, err := json.Marshal(map[string]string{"command": command})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/scales/"+url.PathEscape(scaleID)+"/commands", nil).String(), bytes.NewReader(body))
+	if err != nil {
+		return CommandResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp commandResponse
+	err = c.do(req, &resp)
+	var se *StatusError
+	if errors.As(err, &se) && resp.Message != "" {
+		se.Message = resp.Message
+	}
+	return resp.Result, err
+}
+
+// Print 上传 PDF 并等待打印完成；printer 为空时使用服务端配置的打印机，需要 print 权限的令牌
+func (c *Client) Print(ctx context.Context, filename string, pdf io.Reader, printer string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, pdf); err != nil {
+		return err
+	}
+	if printer != "" {
+		if err := form.WriteField("printer", printer); err != nil {
+			return err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/print", nil).String(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return c.do(req, nil)
+}
+
+// streamQuery 返回订阅连接的查询参数
+func (c *Client) streamQuery(since uint64, resume bool) url.Values {
+	query := url.Values{}
+	if len(c.topics) > 0 {
+		query.Set("topics", strings.Join(c.topics, ","))
+	}
+	if c.rate > 0 {
+		query.Set("rate", strconv.FormatFloat(c.rate, 'f', -1, 64))
+	}
+	if resume {
+		query.Set("since", strconv.FormatUint(since, 10))
+	}
+	return query
+}
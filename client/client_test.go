@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"reader/internal/serial"
+	"reader/internal/ws"
+)
+
+// testServer 是可以断开全部连接、暂时拒绝新连接的 hub 服务
+type testServer struct {
+	*httptest.Server
+	hub    *ws.Hub
+	reject atomic.Bool
+	mu     sync.Mutex
+	conns  []net.Conn
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{hub: ws.NewHub(ws.Config{ReplaySize: 16})}
+	mux := http.NewServeMux()
+	guard := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if s.reject.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("/ws", guard(s.hub.HandleWS))
+	mux.HandleFunc("/events", guard(s.hub.HandleSSE))
+	s.Server = httptest.NewUnstartedServer(mux)
+	s.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// dropAll 断开全部连接，包括已升级的 WebSocket
+func (s *testServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func statusEvent(scaleID, state string) *ws.Message {
+	return ws.NewEventMessage(ws.TypeStatus, scaleID, serial.Event{
+		Type: serial.EventStatus, ScaleID: scaleID, At: time.Now(), State: state, Port: "COM3",
+	})
+}
+
+func waitClients(t *testing.T, hub *ws.Hub) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.GetClientCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not register")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message")
+		panic("unreachable")
+	}
+}
+
+func TestStreamReconnectAndResume(t *testing.T) {
+	for _, transport := range []string{"ws", "sse"} {
+		t.Run(transport, func(t *testing.T) {
+			srv := newTestServer(t)
+			opts := []Option{WithBackoff(10*time.Millisecond, 20*time.Millisecond)}
+			if transport == "sse" {
+				opts = append(opts, WithSSE())
+			}
+			c, err := New(srv.URL, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			readings := make(chan Reading, 16)
+			events := make(chan Event, 16)
+			connects := make(chan struct{}, 4)
+			disconnects := make(chan error, 4)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- c.Stream(ctx, Handlers{
+					Reading: func(r Reading) { readings <- r },
+					Event:   func(e Event) { events <- e },
+					Resync:  func(r Resync) { t.Errorf("unexpected resync %+v", r) },
+					Connect: func() { connects <- struct{}{} },
+					Disconnect: func(err error) {
+						select {
+						case disconnects <- err:
+						default:
+						}
+					},
+				})
+			}()
+
+			receive(t, connects)
+			waitClients(t, srv.hub)
+			srv.hub.Publish(ws.NewWeightMessage("a", 12.5, "kg", true, "GS", "", time.Now()))
+			srv.hub.Publish(statusEvent("a", serial.StateConnected))
+			if r := receive(t, readings); r.ScaleID != "a" || r.Weight != 12.5 || !r.Stable || r.Mode != "GS" {
+				t.Fatalf("reading = %+v", r)
+			}
+			if e := receive(t, events); e.Seq != 1 || e.Status == nil || e.Status.State != serial.StateConnected || e.Status.Port != "COM3" {
+				t.Fatalf("event = %+v", e)
+			}
+
+			// 断线期间发布的事件在重连后补发
+			srv.reject.Store(true)
+			srv.dropAll()
+			receive(t, disconnects)
+			srv.hub.Publish(statusEvent("a", serial.StateDisconnected))
+			srv.hub.Publish(statusEvent("a", serial.StateConnected))
+			srv.reject.Store(false)
+
+			receive(t, connects)
+			if r := receive(t, readings); !r.Snapshot || r.Weight != 12.5 {
+				t.Fatalf("snapshot reading = %+v", r)
+			}
+			for _, want := range []struct {
+				seq   uint64
+				state string
+			}{{2, serial.StateDisconnected}, {3, serial.StateConnected}} {
+				if e := receive(t, events); e.Seq != want.seq || e.Snapshot || e.Status.State != want.state {
+					t.Fatalf("resumed event = %+v, want seq %d %s", e, want.seq, want.state)
+				}
+			}
+
+			cancel()
+			if err := receive(t, done); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Stream() = %v", err)
+			}
+		})
+	}
+}
+
+func TestHTTPAPIs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scales/a/commands", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "未授权", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{"success":false,"result":{"scaleId":"a","command":"zero","checkable":true},"message":"指令未生效"}`)
+	})
+	mux.HandleFunc("/print", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil || header.Filename != "a.pdf" || r.FormValue("printer") != "P1" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) != "%PDF" {
+			http.Error(w, "bad content", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"success":true}`)
+	})
+	mux.HandleFunc("/scales/a/weight", func(w http.ResponseWriter, r *http.Request) {
+		// 等待超时仍返回 200 和最新读数
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"scaleId":"a","connected":true,"weight":12.5,"unit":"kg","stable":false}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	ctx := context.Background()
+
+	anonymous, _ := New(srv.URL)
+	if w, err := anonymous.WaitStable(ctx, "a", time.Second); err != ErrNotStable || w.Weight == nil || *w.Weight != 12.5 {
+		t.Fatalf("WaitStable = %+v, %v", w, err)
+	}
+	var se *StatusError
+	if _, err := anonymous.SendCommand(ctx, "a", "zero"); !errors.As(err, &se) || se.Code != http.StatusUnauthorized {
+		t.Fatalf("SendCommand without token = %v", err)
+	}
+
+	c, _ := New(srv.URL, WithToken("secret"))
+	result, err := c.SendCommand(ctx, "a", "zero")
+	if !errors.As(err, &se) || se.Code != http.StatusBadGateway || se.Message != "指令未生效" || result.Command != "zero" || !result.Checkable {
+		t.Fatalf("SendCommand = %+v, %v", result, err)
+	}
+	if err := c.Print(ctx, "a.pdf", strings.NewReader("%PDF"), "P1"); err != nil {
+		t.Fatalf("Print = %v", err)
+	}
+}
+
+func TestStreamResumesOnGap(t *testing.T) {
+	var lastIDs []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		first := len(lastIDs) == 1
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		event := func(seq int) {
+			fmt.Fprintf(w, "id: %d\nevent: status\ndata: {\"type\":\"status\",\"scaleId\":\"a\",\"stream\":\"events\",\"seq\":%d,\"data\":{\"state\":\"connected\"}}\n\n", seq, seq)
+		}
+		if first {
+			// 第 2 条在服务端丢失
+			event(1)
+			event(3)
+		} else {
+			event(2)
+			event(3)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c, _ := New(srv.URL, WithSSE(), WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	events := make(chan Event, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Stream(ctx, Handlers{Event: func(e Event) { events <- e }})
+
+	for _, want := range []uint64{1, 2, 3} {
+		if e := receive(t, events); e.Seq != want {
+			t.Fatalf("event seq = %d, want %d", e.Seq, want)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastIDs) != 2 || lastIDs[1] != "1" {
+		t.Fatalf("Last-Event-ID = %q, want reconnect from 1", lastIDs)
+	}
+}
+
+// 客户端不依赖服务端包，协议常量必须与服务端一致
+func TestProtocolConstants(t *testing.T) {
+	for _, c := range []struct{ got, want string }{
+		{EventStatus, ws.TypeStatus},
+		{EventPrintJob, ws.TypePrintJob},
+		{typeWeight, ws.TypeWeight},
+		{typeResync, ws.TypeResync},
+		{streamEvents, ws.StreamEvents},
+		{subprotocolJSON, ws.SubprotocolJSON},
+	} {
+		if c.got != c.want {
+			t.Errorf("constant = %q, server uses %q", c.got, c.want)
+		}
+	}
+}
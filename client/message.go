@@ -0,0 +1,143 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 事件类型，与服务端 JSON 信封的 type 相同
+const (
+	EventStatus   = "status"    // 地磅连接、断开和 USB 插拔
+	EventPrintJob = "print_job" // 打印任务状态变化
+)
+
+// 推送协议中的其他常量，与服务端保持一致
+const (
+	typeWeight      = "weight"
+	typeResync      = "resync" // 无法续传，随后发送最新状态快照
+	streamEvents    = "events" // 事件流，断线后按序号补发
+	subprotocolJSON = "weighbridge.v1.json"
+)
+
+// Reading 是一次重量读数
+type Reading struct {
+	ScaleID  string
+	Weight   float64
+	Unit     string
+	Stable   bool
+	Mode     string // GS 毛重，NT 净重
+	At       time.Time
+	Seq      uint64        // 在读数流内递增，读数可能被合并或限流，序号不连续
+	Snapshot bool          // 连接或重连时补发的最新读数
+	Age      time.Duration // 补发的读数距今的时间
+}
+
+// Status 是地磅连接状态
+type Status struct {
+	State  string `json:"state"` // connected、disconnected、attached、detached
+	Port   string `json:"port,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PrintJob 是打印任务状态
+type PrintJob struct {
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"`
+	Printer  string    `json:"printer,omitempty"`
+	State    string    `json:"state"` // queued、printing、completed、failed
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Event 是状态或打印任务事件，按 Type 填写 Status 或 PrintJob 之一
+type Event struct {
+	Type     string
+	ScaleID  string
+	Seq      uint64 // 在事件流内连续递增，重连时从最后收到的序号续传
+	At       time.Time
+	Snapshot bool // 连接时补发的最新状态，如进行中的打印任务
+
+	Status   *Status
+	PrintJob *PrintJob
+	Data     json.RawMessage // 原始事件内容
+}
+
+// Resync 表示服务端无法补发断线期间的全部事件，随后会收到完整的最新状态
+type Resync struct {
+	Reason string `json:"reason"`
+	Since  uint64 `json:"since"`
+	Oldest uint64 `json:"oldest"`
+	Latest uint64 `json:"latest"`
+}
+
+// envelope 是服务端 JSON 信封
+type envelope struct {
+	Type     string          `json:"type"`
+	ScaleID  string          `json:"scaleId"`
+	Weight   *float64        `json:"weight"`
+	Unit     string          `json:"unit"`
+	Stable   *bool           `json:"stable"`
+	Status   string          `json:"status"`
+	TS       int64           `json:"ts"`
+	Stream   string          `json:"stream"`
+	Seq      uint64          `json:"seq"`
+	Data     json.RawMessage `json:"data"`
+	Snapshot bool            `json:"snapshot"`
+	AgeMs    int64           `json:"age"`
+}
+
+// scaleEvent 是地磅状态事件的 data
+type scaleEvent struct {
+	At     time.Time `json:"at"`
+	State  string    `json:"state"`
+	Port   string    `json:"port"`
+	Reason string    `json:"reason"`
+}
+
+func (e *envelope) reading() Reading {
+	r := Reading{
+		ScaleID:  e.ScaleID,
+		Unit:     e.Unit,
+		Mode:     e.Status,
+		At:       time.UnixMilli(e.TS),
+		Seq:      e.Seq,
+		Snapshot: e.Snapshot,
+		Age:      time.Duration(e.AgeMs) * time.Millisecond,
+	}
+	if e.Weight != nil {
+		r.Weight = *e.Weight
+	}
+	if e.Stable != nil {
+		r.Stable = *e.Stable
+	}
+	return r
+}
+
+func (e *envelope) event() (Event, error) {
+	ev := Event{
+		Type:     e.Type,
+		ScaleID:  e.ScaleID,
+		Seq:      e.Seq,
+		At:       time.UnixMilli(e.TS),
+		Snapshot: e.Snapshot,
+		Data:     e.Data,
+	}
+	switch e.Type {
+	case EventPrintJob:
+		var job PrintJob
+		if err := json.Unmarshal(e.Data, &job); err != nil {
+			return ev, err
+		}
+		ev.PrintJob = &job
+	case EventStatus:
+		var data scaleEvent
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return ev, err
+		}
+		if !data.At.IsZero() {
+			ev.At = data.At
+		}
+		ev.Status = &Status{State: data.State, Port: data.Port, Reason: data.Reason}
+	}
+	return ev, nil
+}
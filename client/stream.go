@@ -0,0 +1,272 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// idleTimeout 内没有收到任何消息或心跳时认为连接已断开。服务端默认每 30 秒发送一次心跳。
+const idleTimeout = 2 * time.Minute
+
+// Handlers 是 Stream 收到消息时的回调，在同一个goroutine中依次调用，不能阻塞太久；未设置的回调忽略对应消息
+type Handlers struct {
+	Reading    func(Reading)
+	Event      func(Event)
+	Resync     func(Resync)    // 无法续传时调用，随后会收到完整的最新状态
+	Connect    func()          // 连接建立（包括重连）
+	Disconnect func(err error) // 连接断开或连接失败，随后自动重连
+}
+
+// stream 是一次 Stream 调用的状态，记录最后收到的事件序号用于续传
+type stream struct {
+	client   *Client
+	handlers Handlers
+	since    uint64
+	resume   bool
+}
+
+// Stream 连接服务并持续接收读数和事件，断线后按指数退避重连，并从最后收到的事件序号续传。
+// ctx 结束时返回 ctx.Err()；令牌无效或权限不足时直接返回 StatusError，不再重试。
+func (c *Client) Stream(ctx context.Context, h Handlers) error {
+	s := &stream{client: c, handlers: h, since: c.since, resume: c.since > 0}
+	attempt := 0
+	for {
+		var connected bool
+		var err error
+		if c.sse {
+			connected, err = s.runSSE(ctx)
+		} else {
+			connected, err = s.runWS(ctx)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if permanent(err) {
+			return err
+		}
+		if connected {
+			attempt = 0
+		}
+		if h.Disconnect != nil {
+			h.Disconnect(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+		attempt++
+	}
+}
+
+// backoff 返回第 attempt 次重连前的等待时间，每次翻倍，加入 ±20% 抖动避免大量客户端同时重连
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.maxBackoff
+	if attempt < 30 {
+		if next := c.minBackoff << attempt; next > 0 && next < d {
+			d = next
+		}
+	}
+	return d - d/5 + time.Duration(rand.Int63n(int64(d/5)*2+1))
+}
+
+func (s *stream) connected() {
+	if s.handlers.Connect != nil {
+		s.handlers.Connect()
+	}
+}
+
+// handle 解码一帧：单条 JSON 信封，或合并发送的信封数组。返回错误时应断开并重连续传。
+func (s *stream) handle(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil
+		}
+		for _, item := range batch {
+			if err := s.handleMessage(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s.handleMessage(data)
+}
+
+// handleMessage 解码一条 JSON 信封并调用对应的回调；事件序号不连续时返回错误，不调用回调
+func (s *stream) handleMessage(data []byte) error {
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil
+	}
+	switch e.Type {
+	case typeWeight:
+		if s.handlers.Reading != nil {
+			s.handlers.Reading(e.reading())
+		}
+	case typeResync:
+		var r Resync
+		json.Unmarshal(e.Data, &r)
+		// 随后的快照就是最新状态，之后从服务端当前的序号续传
+		s.since, s.resume = r.Latest, true
+		if s.handlers.Resync != nil {
+			s.handlers.Resync(r)
+		}
+	case EventStatus, EventPrintJob:
+		if e.Stream == streamEvents && !e.Snapshot {
+			// 序号在全部主题间递增，只订阅部分主题时本来就不连续
+			if s.resume && len(s.client.topics) == 0 && e.Seq > s.since+1 {
+				return fmt.Errorf("事件序号不连续: 最后收到 %d，收到 %d", s.since, e.Seq)
+			}
+			s.since, s.resume = e.Seq, true
+		}
+		if s.handlers.Event != nil {
+			ev, _ := e.event() // 内容无法解码时仍然回调，Data 为原始内容
+			s.handlers.Event(ev)
+		}
+	}
+	return nil
+}
+
+// runWS 通过 /ws 接收消息直到连接断开，connected 表示连接曾经建立
+func (s *stream) runWS(ctx context.Context) (connected bool, err error) {
+	c := s.client
+	query := c.streamQuery(s.since, s.resume)
+	query.Set("batch", "true")
+	u := c.endpoint("/ws", query)
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	header := http.Header{}
+	c.authorize(header)
+	dialer := websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  10 * time.Second,
+		Subprotocols:      []string{subprotocolJSON},
+		EnableCompression: true,
+	}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			return false, statusError(resp)
+		}
+		return false, err
+	}
+	defer conn.Close()
+	s.connected()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if err := s.handle(data); err != nil {
+			return true, err
+		}
+	}
+}
+
+// runSSE 通过 /events 接收消息直到连接断开，connected 表示连接曾经建立
+func (s *stream) runSSE(ctx context.Context) (connected bool, err error) {
+	c := s.client
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/events", c.streamQuery(0, false)).String(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if s.resume {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(s.since, 10))
+	}
+	c.authorize(req.Header)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, statusError(resp)
+	}
+	s.connected()
+
+	idle := time.AfterFunc(idleTimeout, cancel)
+	defer idle.Stop()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		idle.Reset(idleTimeout)
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "close" {
+				return true, fmt.Errorf("服务端关闭连接: %s", strings.Join(data, "\n"))
+			}
+			if len(data) > 0 {
+				if err := s.handle([]byte(strings.Join(data, "\n"))); err != nil {
+					return true, err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// 注释行是心跳
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, io.EOF
+}
+
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// 令牌权限范围，admin 包含全部权限
+const (
+	ScopeRead  = "read"  // 读取重量和状态
+	ScopePrint = "print" // 提交打印任务
+	ScopeAdmin = "admin" // 修改配置、发送仪表指令、调试和管理连接
+)
+
+// Identity 是请求方的身份
+type Identity struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Anonymous 是未携带令牌的请求方的身份，只能读取和打印；admin 权限必须使用令牌
+var Anonymous = Identity{Name: "anonymous", Scopes: []string{ScopeRead, ScopePrint}}
+
+// Has 判断是否拥有某项权限
+func (id Identity) Has(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// WithIdentity 把身份存入请求上下文
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom 读取中间件存入的身份，不存在时返回 Anonymous
+func IdentityFrom(ctx context.Context) Identity {
+	if id, ok := ctx.Value(identityKey{}).(Identity); ok {
+		return id
+	}
+	return Anonymous
+}
+
+// TokenFromRequest 读取 Authorization: Bearer 头；浏览器的 WebSocket 无法设置请求头，也接受 ?token= 参数
+func TokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := cutPrefixFold(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
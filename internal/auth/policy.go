@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Token 是一个访问令牌及其权限
+type Token struct {
+	Name   string
+	Token  string
+	Scopes []string
+}
+
+// Config 是来源和令牌认证配置
+type Config struct {
+	AllowedOrigins []string // 允许的浏览器来源，如 http://localhost:8080；为空或包含 * 时允许任意来源
+	Tokens         []Token  // 为空时读取和打印接口无需令牌，admin 接口总是需要令牌
+	AdminToken     string   // 兼容旧配置，等同于一个 admin 权限的令牌
+}
+
+// Policy 检查请求来源、设置 CORS 响应头并按令牌权限放行
+type Policy struct {
+	anyOrigin bool
+	origins   map[string]struct{}
+	tokens    []Token
+	required  bool // 是否对普通接口启用令牌认证
+}
+
+// NewPolicy 校验配置并创建 Policy
+func NewPolicy(cfg Config) (*Policy, error) {
+	p := &Policy{origins: make(map[string]struct{}), required: len(cfg.Tokens) > 0}
+	if len(cfg.AllowedOrigins) == 0 {
+		p.anyOrigin = true
+	}
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		p.origins[strings.ToLower(o)] = struct{}{}
+	}
+
+	for _, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("令牌 %q 为空", t.Name)
+		}
+		for _, s := range t.Scopes {
+			if s != ScopeRead && s != ScopePrint && s != ScopeAdmin {
+				return nil, fmt.Errorf("令牌 %q 的权限无效: %s", t.Name, s)
+			}
+		}
+		p.tokens = append(p.tokens, t)
+	}
+	if cfg.AdminToken != "" {
+		p.tokens = append(p.tokens, Token{Name: "admin", Token: cfg.AdminToken, Scopes: []string{ScopeAdmin}})
+	}
+	return p, nil
+}
+
+// AnyOrigin 返回是否允许任意来源
+func (p *Policy) AnyOrigin() bool {
+	return p.anyOrigin
+}
+
+// CheckOrigin 判断请求来源是否允许；没有 Origin 头的请求不是来自浏览器页面，总是允许
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.anyOrigin {
+		return true
+	}
+	_, ok := p.origins[strings.ToLower(origin)]
+	return ok
+}
+
+// lookup 按令牌查找身份
+func (p *Policy) lookup(token string) (Identity, bool) {
+	if token == "" {
+		return Identity{}, false
+	}
+	var found *Token
+	for i := range p.tokens {
+		// 逐个比较全部令牌，耗时与匹配位置无关
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.tokens[i].Token)) == 1 && found == nil {
+			found = &p.tokens[i]
+		}
+	}
+	if found == nil {
+		return Identity{}, false
+	}
+	return Identity{Name: found.Name, Scopes: found.Scopes}, true
+}
+
+// setCORS 为允许的来源设置 CORS 响应头
+func (p *Policy) setCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+}
+
+func (p *Policy) reject(w http.ResponseWriter, r *http.Request, status int, reason string) {
+	logrus.WithFields(logrus.Fields{
+		"module": "Auth",
+		"path":   r.URL.Path,
+		"remote": r.RemoteAddr,
+		"origin": r.Header.Get("Origin"),
+		"reason": reason,
+	}).Warn("拒绝请求")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	http.Error(w, reason, status)
+}
+
+// Require 检查来源，并要求令牌拥有 scope 权限；未启用令牌认证时匿名请求可以读取和打印，admin 权限总是需要令牌
+func (p *Policy) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return p.handle(scope, false, next)
+}
+
+// RequireAdmin 要求携带 admin 权限的令牌，未启用令牌认证时也不例外；没有配置任何令牌时拒绝所有请求
+func (p *Policy) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return p.handle(ScopeAdmin, true, next)
+}
+
+func (p *Policy) handle(scope string, tokenRequired bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !p.CheckOrigin(r) {
+			p.reject(w, r, http.StatusForbidden, "来源不允许")
+			return
+		}
+		p.setCORS(w, r)
+		if r.Method == http.MethodOptions {
+			// 预检请求不携带令牌
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		id := Anonymous
+		token := TokenFromRequest(r)
+		// 匿名可以访问的接口也按携带的令牌识别身份，如带 admin 令牌连接 /ws 后发送指令
+		if p.required || tokenRequired || !id.Has(scope) || (token != "" && len(p.tokens) > 0) {
+			var ok bool
+			if id, ok = p.lookup(token); !ok {
+				p.reject(w, r, http.StatusUnauthorized, "未授权")
+				return
+			}
+			if !id.Has(scope) {
+				p.reject(w, r, http.StatusForbidden, "权限不足")
+				return
+			}
+		}
+		next(w, r.WithContext(WithIdentity(r.Context(), id)))
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(Config{
+		AllowedOrigins: []string{"http://localhost:8080/"},
+		Tokens: []Token{
+			{Name: "kiosk", Token: "k", Scopes: []string{ScopeRead}},
+			{Name: "office", Token: "o", Scopes: []string{ScopeRead, ScopePrint}},
+		},
+		AdminToken: "a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Identity
+	handler := p.Require(ScopePrint, func(w http.ResponseWriter, r *http.Request) {
+		got = IdentityFrom(r.Context())
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		origin string
+		auth   string
+		query  string
+		want   int
+	}{
+		{"no token", http.MethodPost, "", "", "", http.StatusUnauthorized},
+		{"read only", http.MethodPost, "", "Bearer k", "", http.StatusForbidden},
+		{"print scope", http.MethodPost, "http://localhost:8080", "Bearer o", "", http.StatusOK},
+		{"query token", http.MethodPost, "", "", "?token=o", http.StatusOK},
+		{"admin implies print", http.MethodPost, "", "Bearer a", "", http.StatusOK},
+		{"other origin", http.MethodPost, "http://evil.example", "Bearer o", "", http.StatusForbidden},
+		{"preflight", http.MethodOptions, "http://localhost:8080", "", "", http.StatusNoContent},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/print"+c.query, nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.want)
+		}
+		if c.origin == "http://localhost:8080" && w.Header().Get("Access-Control-Allow-Origin") != c.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", c.name, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+	if got.Name != "admin" {
+		t.Errorf("identity = %+v, want admin", got)
+	}
+}
+
+func TestPolicyWithoutTokens(t *testing.T) {
+	p, err := NewPolicy(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	w := httptest.NewRecorder()
+	p.Require(ScopeRead, ok)(w, httptest.NewRequest(http.MethodGet, "/scales/a/weight", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("anonymous read without tokens configured: status = %d, want 200", w.Code)
+	}
+
+	// 没有配置令牌时不能匿名修改设置或发送指令
+	w = httptest.NewRecorder()
+	p.Require(ScopeAdmin, ok)(w, httptest.NewRequest(http.MethodPost, "/scales/a/commands", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous command without tokens configured: status = %d, want 401", w.Code)
+	}
+
+	// 调试和管理接口始终需要令牌
+	w = httptest.NewRecorder()
+	p.RequireAdmin(ok)(w, httptest.NewRequest(http.MethodGet, "/ws/debug", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("admin endpoint without admin token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestNewPolicyRejectsUnknownScope(t *testing.T) {
+	if _, err := NewPolicy(Config{Tokens: []Token{{Name: "x", Token: "x", Scopes: []string{"write"}}}}); err == nil {
+		t.Fatal("NewPolicy() accepted an unknown scope")
+	}
+}
+
+func TestPolicyWithOnlyAdminToken(t *testing.T) {
+	p, err := NewPolicy(Config{AdminToken: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Identity
+	ok := func(w http.ResponseWriter, r *http.Request) { got = IdentityFrom(r.Context()) }
+
+	cases := []struct {
+		name  string
+		scope string
+		auth  string
+		want  int
+		admin bool
+	}{
+		{"anonymous read", ScopeRead, "", http.StatusOK, false},
+		{"anonymous print", ScopePrint, "", http.StatusOK, false},
+		{"anonymous settings", ScopeAdmin, "", http.StatusUnauthorized, false},
+		{"admin settings", ScopeAdmin, "Bearer a", http.StatusOK, true},
+		{"admin token on read endpoint", ScopeRead, "Bearer a", http.StatusOK, true},
+		{"wrong token", ScopeRead, "Bearer x", http.StatusUnauthorized, false},
+	}
+	for _, c := range cases {
+		got = Identity{}
+		r := httptest.NewRequest(http.MethodPost, "/scales/a/settings", nil)
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		p.Require(c.scope, ok)(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.want)
+		}
+		if w.Code == http.StatusOK && got.Has(ScopeAdmin) != c.admin {
+			t.Errorf("%s: identity = %+v, admin = %v", c.name, got, c.admin)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,16 +14,133 @@ type MockMessage struct {
 	Message string `json:"message"`
 }
 
+// TCP 共享配置，把地磅数据流转发给旧版称重软件
+type ShareConfig struct {
+	Port       int    `json:"port"`
+	Mode       string `json:"mode"`        // raw（默认）或 rfc2217
+	Format     string `json:"format"`      // raw（默认，原样转发）或地磅型号，按该型号格式重新输出
+	MaxClients int    `json:"max_clients"` // 0 表示不限制
+}
+
+// 大屏幕输出配置，把当前读数写到另一个串口或 TCP 连接
+type DisplayConfig struct {
+	Target   string `json:"target"`    // 串口名，或 tcp://host:port
+	BaudRate int    `json:"baud_rate"` // 默认 9600
+	Protocol string `json:"protocol"`  // ascii（默认）或 xk3190
+	Width    int    `json:"width"`     // ascii 协议的显示宽度，默认 8
+	Pad      string `json:"pad"`       // ascii 协议的填充字符，默认空格
+	Interval int    `json:"interval"`  // 毫秒，刷新间隔，默认 200
+}
+
+// 访问令牌配置
+type TokenConfig struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"` // read、print、admin
+}
+
+// WebSocket 推送配置
+type WSConfig struct {
+	PingInterval int `json:"ping_interval"` // 毫秒，心跳 ping 间隔，0 表示不发送心跳
+	PongTimeout  int `json:"pong_timeout"`  // 毫秒，ping 之后等待 pong 的最长时间
+	WriteTimeout int `json:"write_timeout"` // 毫秒，单条消息写入的最长时间
+	// 客户端处理不过来时的策略：conflate（只保留最新读数）、drop_oldest 或 disconnect
+	SlowPolicy string `json:"slow_policy"`
+	BufferSize int    `json:"buffer_size"` // 每个客户端的发送队列长度
+	MaxDrops   int    `json:"max_drops"`   // disconnect 策略下连续丢弃多少条后断开
+	// SSE 客户端单独的慢客户端策略，未填写的字段沿用上面 WebSocket 的配置
+	SSE        BackpressureConfig `json:"sse"`
+	ReplaySize int                `json:"replay_size"` // 保留最近多少条消息用于断线续传
+	MaxRate    float64            `json:"max_rate"`    // 每台地磅每秒最多推送几条读数，0 表示不限制
+	MaxClients int                `json:"max_clients"` // WebSocket 和 SSE 连接总数上限，0 表示不限制
+	// 客户端支持时启用 permessage-deflate 压缩
+	Compression          bool `json:"compression"`
+	CompressionLevel     int  `json:"compression_level"`     // 1（最快）到 9（最小）
+	CompressionThreshold int  `json:"compression_threshold"` // 字节，小于该长度的消息不压缩
+}
+
+// 推送端点的慢客户端策略
+type BackpressureConfig struct {
+	SlowPolicy string `json:"slow_policy"` // conflate、drop_oldest 或 disconnect
+	BufferSize int    `json:"buffer_size"` // 每个客户端的发送队列长度
+	MaxDrops   int    `json:"max_drops"`   // disconnect 策略下连续丢弃多少条后断开
+}
+
+// 单台地磅配置，未填写的字段沿用顶层配置
+type ScaleConfig struct {
+	ID         string `json:"id"`
+	SerialPort string `json:"serial_port"`
+	BaudRate   int    `json:"baud_rate"`
+	ScaleModel string `json:"scale_model"`
+	// 毫秒，超过该时间无数据则重新打开端口；0 沿用顶层配置，负数关闭看门狗
+	WatchdogTimeout int `json:"watchdog_timeout"`
+	// 按 USB 信息识别适配器，重新插拔后端口号变化也能找到设备
+	USBVID    string `json:"usb_vid"`
+	USBPID    string `json:"usb_pid"`
+	USBSerial string `json:"usb_serial"`
+	// 为空时不开启 TCP 共享
+	Share *ShareConfig `json:"share"`
+	// 为空时不输出到大屏幕
+	Display *DisplayConfig `json:"display"`
+}
+
 // 配置结构体
 type Config struct {
-	SerialPort        string        `json:"serial_port"`
-	BaudRate          int           `json:"baud_rate"`
-	ScaleModel        string        `json:"scale_model"`
-	WebsocketPort     int           `json:"websocket_port"`
-	PrinterName       string        `json:"printer_name"`
-	MockMode          bool          `json:"mock_mode"`
-	MockMessages      []MockMessage `json:"mock_messages"`
-	BroadcastInterval int           `json:"broadcast_interval"` // 毫秒
+	SerialPort        string         `json:"serial_port"`
+	BaudRate          int            `json:"baud_rate"`
+	ScaleModel        string         `json:"scale_model"`
+	WebsocketPort     int            `json:"websocket_port"`
+	PrinterName       string         `json:"printer_name"`
+	MockMode          bool           `json:"mock_mode"`
+	MockMessages      []MockMessage  `json:"mock_messages"`
+	BroadcastInterval int            `json:"broadcast_interval"` // 毫秒
+	Scales            []ScaleConfig  `json:"scales"`
+	CommandTimeout    int            `json:"command_timeout"`  // 毫秒，等待指令生效的最长时间
+	WatchdogTimeout   int            `json:"watchdog_timeout"` // 毫秒，0 或负数表示关闭无数据看门狗
+	HotplugInterval   int            `json:"hotplug_interval"` // 毫秒，枚举串口检测热插拔的间隔，0 表示关闭
+	AdminToken        string         `json:"admin_token"`      // 管理接口令牌，为空时管理接口不可用
+	AllowedOrigins    []string       `json:"allowed_origins"`  // 允许访问的浏览器来源，为空时允许任意来源
+	Tokens            []TokenConfig  `json:"tokens"`           // 访问令牌，为空时不启用令牌认证
+	Share             *ShareConfig   `json:"share"`            // 未配置 scales 时默认地磅的 TCP 共享
+	Display           *DisplayConfig `json:"display"`          // 未配置 scales 时默认地磅的大屏输出
+	WS                WSConfig       `json:"ws"`
+	ShutdownTimeout   int            `json:"shutdown_timeout"` // 毫秒，关闭时等待客户端断开和打印任务完成的最长时间
+}
+
+// DefaultScaleID 是未配置 scales 时顶层串口对应的地磅ID
+const DefaultScaleID = "default"
+
+// ScaleConfigs 返回所有地磅配置；未配置 scales 时使用顶层串口配置
+func (c *Config) ScaleConfigs() []ScaleConfig {
+	if len(c.Scales) == 0 {
+		return []ScaleConfig{{
+			ID:              DefaultScaleID,
+			SerialPort:      c.SerialPort,
+			BaudRate:        c.BaudRate,
+			ScaleModel:      c.ScaleModel,
+			WatchdogTimeout: c.WatchdogTimeout,
+			Share:           c.Share,
+			Display:         c.Display,
+		}}
+	}
+
+	scales := make([]ScaleConfig, 0, len(c.Scales))
+	for i, sc := range c.Scales {
+		if sc.ID == "" {
+			sc.ID = fmt.Sprintf("scale%d", i+1)
+		}
+		if sc.BaudRate == 0 {
+			sc.BaudRate = c.BaudRate
+		}
+		if sc.ScaleModel == "" {
+			sc.ScaleModel = c.ScaleModel
+		}
+		if sc.WatchdogTimeout == 0 {
+			sc.WatchdogTimeout = c.WatchdogTimeout
+		}
+		scales = append(scales, sc)
+	}
+	return scales
 }
 
 var defaultConfig = Config{
@@ -33,6 +151,21 @@ var defaultConfig = Config{
 	PrinterName:       "",
 	MockMode:          false,
 	BroadcastInterval: 500,
+	CommandTimeout:    3000,
+	WatchdogTimeout:   10000,
+	HotplugInterval:   2000,
+	ShutdownTimeout:   10000,
+	WS: WSConfig{
+		PingInterval:         30000,
+		PongTimeout:          10000,
+		WriteTimeout:         10000,
+		SlowPolicy:           "conflate",
+		BufferSize:           10,
+		MaxDrops:             10,
+		ReplaySize:           256,
+		CompressionLevel:     1,
+		CompressionThreshold: 256,
+	},
 	MockMessages: []MockMessage{
 		{Message: "ST,GS,+000.000kg"},
 		{Message: "ST,GS,+001.234kg"},
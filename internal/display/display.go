@@ -0,0 +1,214 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/sirupsen/logrus"
+	"go.bug.st/serial"
+)
+
+const tcpScheme = "tcp://"
+
+// Config 是大屏幕输出配置
+type Config struct {
+	Target   string        // 串口名，或 tcp://host:port
+	BaudRate int           // 串口波特率
+	Protocol string        // ascii 或 xk3190
+	Width    int           // ascii 协议的显示宽度
+	Pad      string        // ascii 协议的填充字符，空格或 "0"
+	Interval time.Duration // 刷新间隔
+}
+
+// Source 提供最新读数
+type Source interface {
+	ID() string
+	Connected() bool
+	LastReading() (scale.Reading, time.Time, bool)
+}
+
+// staleIntervals 是读数超过多少个刷新间隔未更新就不再显示，至少 minStale
+const (
+	staleIntervals = 5
+	minStale       = 2 * time.Second
+)
+
+// Sink 把地磅当前读数按大屏幕协议定时写到另一个串口或 TCP 连接
+type Sink struct {
+	src  Source
+	cfg  Config
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewSink(src Source, cfg Config) (*Sink, error) {
+	if cfg.Target == "" {
+		return nil, fmt.Errorf("大屏输出目标不能为空")
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolASCII
+	}
+	if cfg.Protocol != ProtocolASCII && cfg.Protocol != ProtocolXK3190 {
+		return nil, fmt.Errorf("不支持的大屏协议 %q", cfg.Protocol)
+	}
+	if cfg.Width <= 0 {
+		cfg.Width = 8
+	}
+	if cfg.Pad == "" {
+		cfg.Pad = " "
+	}
+	if len(cfg.Pad) != 1 {
+		return nil, fmt.Errorf("填充字符必须是单个字符: %q", cfg.Pad)
+	}
+	if cfg.BaudRate <= 0 {
+		cfg.BaudRate = 9600
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 200 * time.Millisecond
+	}
+	return &Sink{src: src, cfg: cfg, done: make(chan struct{})}, nil
+}
+
+func (d *Sink) Start() {
+	logrus.WithFields(logrus.Fields{
+		"module":   "Display",
+		"scale":    d.src.ID(),
+		"target":   d.cfg.Target,
+		"protocol": d.cfg.Protocol,
+		"interval": d.cfg.Interval,
+	}).Info("大屏输出启动")
+
+	d.wg.Add(1)
+	go d.loop()
+}
+
+// Close 停止输出并等待输出循环退出
+func (d *Sink) Close() {
+	d.once.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+func (d *Sink) encode(r scale.Reading) ([]byte, error) {
+	if d.cfg.Protocol == ProtocolXK3190 {
+		return encodeXK3190(r)
+	}
+	return encodeASCII(r, d.cfg.Width, d.cfg.Pad), nil
+}
+
+// staleAfter 返回读数过时的期限
+func (d *Sink) staleAfter() time.Duration {
+	if stale := staleIntervals * d.cfg.Interval; stale > minStale {
+		return stale
+	}
+	return minStale
+}
+
+// frame 返回当前要显示的帧；仪表断开或读数过时时显示横线（xk3190 为空白），避免大屏一直停在旧重量上
+func (d *Sink) frame() ([]byte, error) {
+	reading, receivedAt, ok := d.src.LastReading()
+	if !ok || !d.src.Connected() || time.Since(receivedAt) > d.staleAfter() {
+		if d.cfg.Protocol == ProtocolXK3190 {
+			return blankXK3190(), nil
+		}
+		return blankASCII(d.cfg.Width), nil
+	}
+	return d.encode(reading)
+}
+
+func (d *Sink) open() (io.WriteCloser, error) {
+	if strings.HasPrefix(d.cfg.Target, tcpScheme) {
+		return net.DialTimeout("tcp", strings.TrimPrefix(d.cfg.Target, tcpScheme), 5*time.Second)
+	}
+	return serial.Open(d.cfg.Target, &serial.Mode{BaudRate: d.cfg.BaudRate})
+}
+
+// loop 定时写入最新读数；大屏断开时关闭连接，按退避间隔重新连接
+func (d *Sink) loop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	var (
+		out       io.WriteCloser
+		failures  int
+		nextRetry time.Time
+	)
+	defer func() {
+		if out != nil {
+			out.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-d.done:
+			logrus.WithFields(logrus.Fields{
+				"module": "Display",
+				"scale":  d.src.ID(),
+			}).Info("大屏输出停止")
+			return
+		case <-ticker.C:
+		}
+
+		frame, err := d.frame()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "Display",
+				"scale":  d.src.ID(),
+				"error":  err,
+			}).Debug("读数无法在大屏显示")
+			continue
+		}
+
+		if out == nil {
+			if time.Now().Before(nextRetry) {
+				continue
+			}
+			out, err = d.open()
+			if err != nil {
+				failures++
+				delay := time.Duration(failures) * time.Second
+				if delay > 30*time.Second {
+					delay = 30 * time.Second
+				}
+				nextRetry = time.Now().Add(delay)
+				logrus.WithFields(logrus.Fields{
+					"module":     "Display",
+					"scale":      d.src.ID(),
+					"target":     d.cfg.Target,
+					"error":      err,
+					"retryDelay": delay,
+				}).Error("连接大屏失败")
+				continue
+			}
+			failures = 0
+			logrus.WithFields(logrus.Fields{
+				"module": "Display",
+				"scale":  d.src.ID(),
+				"target": d.cfg.Target,
+			}).Info("大屏已连接")
+		}
+
+		if conn, ok := out.(net.Conn); ok {
+			conn.SetWriteDeadline(time.Now().Add(d.cfg.Interval + time.Second))
+		}
+		if _, err := out.Write(frame); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "Display",
+				"scale":  d.src.ID(),
+				"target": d.cfg.Target,
+				"error":  err,
+			}).Warn("写入大屏失败，断开后重连")
+			out.Close()
+			out = nil
+		}
+	}
+}
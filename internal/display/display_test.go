@@ -0,0 +1,123 @@
+package display
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+)
+
+func TestEncodeASCII(t *testing.T) {
+	tests := []struct {
+		weight string
+		width  int
+		pad    string
+		want   string
+	}{
+		{"+59.6", 8, " ", "    59.6\r\n"},
+		{"-2.5", 6, "0", "-002.5\r\n"},
+		{"12345.6", 4, " ", "12345.6\r\n"},
+	}
+	for _, tt := range tests {
+		got := encodeASCII(scale.Reading{Weight: tt.weight}, tt.width, tt.pad)
+		if string(got) != tt.want {
+			t.Errorf("encodeASCII(%q) = %q, want %q", tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeXK3190(t *testing.T) {
+	got, err := encodeXK3190(scale.Reading{Weight: "+59.6"})
+	if err != nil {
+		t.Fatalf("encodeXK3190() error = %v", err)
+	}
+	if want := "\x02+000596110\x03"; string(got) != want {
+		t.Fatalf("encodeXK3190() = %q, want %q", got, want)
+	}
+
+	if _, err := encodeXK3190(scale.Reading{Weight: "1234567"}); err == nil {
+		t.Fatal("encodeXK3190() should reject weights wider than 6 digits")
+	}
+}
+
+type fixedSource struct{}
+
+func (fixedSource) ID() string      { return "test" }
+func (fixedSource) Connected() bool { return true }
+func (fixedSource) LastReading() (scale.Reading, time.Time, bool) {
+	return scale.Reading{Weight: "+1.5"}, time.Now(), true
+}
+
+func TestSinkReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sink, err := NewSink(fixedSource{}, Config{
+		Target:   tcpScheme + listener.Addr().String(),
+		Width:    6,
+		Interval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	sink.Start()
+	defer sink.Close()
+
+	for i := 0; i < 2; i++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != "   1.5\r\n" {
+			t.Fatalf("display received %q", line)
+		}
+		conn.Close() // 模拟大屏断开，输出端应重新连接
+	}
+}
+
+type staleSource struct {
+	connected bool
+	at        time.Time
+}
+
+func (s staleSource) ID() string      { return "test" }
+func (s staleSource) Connected() bool { return s.connected }
+func (s staleSource) LastReading() (scale.Reading, time.Time, bool) {
+	return scale.Reading{Weight: "+1.5"}, s.at, true
+}
+
+func TestSinkBlanksStaleReadings(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  staleSource
+		want string
+	}{
+		{"fresh", staleSource{connected: true, at: time.Now()}, "   1.5\r\n"},
+		{"disconnected", staleSource{connected: false, at: time.Now()}, "------\r\n"},
+		{"stale", staleSource{connected: true, at: time.Now().Add(-time.Minute)}, "------\r\n"},
+	} {
+		sink, err := NewSink(tc.src, Config{Target: "COM9", Width: 6})
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame, err := sink.frame()
+		if err != nil || string(frame) != tc.want {
+			t.Errorf("%s: frame() = %q, %v, want %q", tc.name, frame, err, tc.want)
+		}
+	}
+
+	sink, _ := NewSink(staleSource{}, Config{Target: "COM9", Protocol: ProtocolXK3190})
+	if frame, _ := sink.frame(); string(frame[2:8]) != "      " || frame[len(frame)-1] != etx {
+		t.Errorf("xk3190 blank frame = %q", frame)
+	}
+}
@@ -0,0 +1,88 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"reader/internal/scale"
+)
+
+const (
+	ProtocolASCII  = "ascii"
+	ProtocolXK3190 = "xk3190"
+)
+
+const (
+	stx = 0x02
+	etx = 0x03
+)
+
+// encodeASCII 输出右对齐的重量文本，例如宽度8、空格填充时为 "    59.6\r\n"
+func encodeASCII(r scale.Reading, width int, pad string) []byte {
+	weight := strings.TrimPrefix(r.Weight, "+")
+	if n := width - len(weight); n > 0 {
+		if pad == "0" && strings.HasPrefix(weight, "-") {
+			weight = "-" + strings.Repeat(pad, n) + weight[1:]
+		} else {
+			weight = strings.Repeat(pad, n) + weight
+		}
+	}
+	return []byte(weight + "\r\n")
+}
+
+// encodeXK3190 输出 XK3190 大屏幕帧：
+// STX、符号、6位不含小数点的重量、小数位数、异或校验高4位、低4位、ETX，共12字节。
+// 校验为符号到小数位数共8字节的异或，每个半字节转成十六进制ASCII字符。
+func encodeXK3190(r scale.Reading) ([]byte, error) {
+	sign := byte('+')
+	weight := r.Weight
+	switch {
+	case strings.HasPrefix(weight, "-"):
+		sign, weight = '-', weight[1:]
+	case strings.HasPrefix(weight, "+"):
+		weight = weight[1:]
+	}
+
+	decimals := 0
+	if i := strings.IndexByte(weight, '.'); i >= 0 {
+		decimals = len(weight) - i - 1
+		weight = weight[:i] + weight[i+1:]
+	}
+	weight = strings.TrimLeft(weight, "0")
+	if len(weight) > 6 || decimals > 9 {
+		return nil, fmt.Errorf("重量 %s 超出大屏显示范围", r.Weight)
+	}
+
+	return xk3190Frame(sign, strings.Repeat("0", 6-len(weight))+weight, decimals), nil
+}
+
+// xk3190Frame 拼接6位重量并计算校验
+func xk3190Frame(sign byte, digits string, decimals int) []byte {
+	frame := make([]byte, 0, 12)
+	frame = append(frame, stx, sign)
+	frame = append(frame, digits...)
+	frame = append(frame, byte('0'+decimals))
+
+	var check byte
+	for _, b := range frame[1:] {
+		check ^= b
+	}
+	return append(frame, hexDigit(check>>4), hexDigit(check&0x0f), etx)
+}
+
+// blankASCII 输出整行横线，表示没有有效读数
+func blankASCII(width int) []byte {
+	return []byte(strings.Repeat("-", width) + "\r\n")
+}
+
+// blankXK3190 输出重量位全为空格的帧，大屏显示为空白
+func blankXK3190() []byte {
+	return xk3190Frame('+', "      ", 0)
+}
+
+func hexDigit(n byte) byte {
+	if n < 10 {
+		return '0' + n
+	}
+	return 'A' + n - 10
+}
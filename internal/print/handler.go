@@ -2,6 +2,7 @@ package print
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reader/internal/config"
@@ -10,8 +11,6 @@ import (
 
 // PrintHandler 是 HTTP 上传和打印的入口
 func PrintHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
 	cfg := config.LoadConfig()
 
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
@@ -53,7 +52,11 @@ func PrintHandler(w http.ResponseWriter, r *http.Request) {
 			"filename": fileHeader.Filename,
 			"error":    err,
 		}).Error("打印失败")
-		http.Error(w, "打印失败: "+err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrClosed) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, "打印失败: "+err.Error(), status)
 		return
 	}
 
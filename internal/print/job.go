@@ -0,0 +1,57 @@
+package print
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 打印任务状态
+const (
+	JobQueued    = "queued"
+	JobPrinting  = "printing"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job 是打印任务的状态变化通知
+type Job struct {
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"`
+	Printer  string    `json:"printer,omitempty"`
+	State    string    `json:"state"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+var (
+	jobSeq      atomic.Uint64
+	listenerMu  sync.RWMutex
+	jobListener func(Job)
+)
+
+// SetJobListener 设置打印任务状态变化的回调，回调不能阻塞
+func SetJobListener(fn func(Job)) {
+	listenerMu.Lock()
+	jobListener = fn
+	listenerMu.Unlock()
+}
+
+func newJobID() string {
+	return strconv.FormatUint(jobSeq.Add(1), 10)
+}
+
+func notifyJob(task printTask, state string, err error) {
+	listenerMu.RLock()
+	fn := jobListener
+	listenerMu.RUnlock()
+	if fn == nil {
+		return
+	}
+	job := Job{ID: task.id, Filename: task.filename, Printer: task.printerName, State: state, At: time.Now()}
+	if err != nil {
+		job.Error = err.Error()
+	}
+	fn(job)
+}
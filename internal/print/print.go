@@ -22,15 +22,18 @@ var (
 )
 
 type printTask struct {
+	id          string
 	pdfContent  io.Reader
 	filename    string
 	printerName string
 	resultChan  chan error
+	resumed     bool // 从 pending 目录恢复的任务，文件仍在磁盘上，关闭时不必再次保存
 }
 
 var (
 	printQueue chan printTask
 	queueOnce  sync.Once
+	printFile  = doPrintPDF // 测试时替换
 )
 
 func startPrintWorker() {
@@ -39,12 +42,17 @@ func startPrintWorker() {
 
 	go func() {
 		for task := range printQueue {
+			if persisting.Load() {
+				persistTask(task)
+				continue
+			}
 			logrus.WithFields(logrus.Fields{
 				"module":   "Print",
 				"filename": task.filename,
 			}).Info("处理打印任务")
+			notifyJob(task, JobPrinting, nil)
 
-			err := doPrintPDF(task.pdfContent, task.filename, task.printerName)
+			err := printFile(task.pdfContent, task.filename, task.printerName)
 			task.resultChan <- err
 			close(task.resultChan)
 
@@ -54,28 +62,43 @@ func startPrintWorker() {
 					"filename": task.filename,
 					"error":    err,
 				}).Error("任务失败")
+				notifyJob(task, JobFailed, err)
 			} else {
 				logrus.WithFields(logrus.Fields{
 					"module":   "Print",
 					"filename": task.filename,
 				}).Info("任务完成")
+				notifyJob(task, JobCompleted, nil)
 			}
+			pending.Done()
 		}
 		logrus.WithField("module", "Print").Info("打印队列处理器退出")
 	}()
 }
 
-// PrintPDF 使用嵌入的打印工具打印指定 PDF 文件内容（队列版）
+// PrintPDF 使用嵌入的打印工具打印指定 PDF 文件内容（队列版），服务关闭后返回 ErrClosed
 func PrintPDF(pdfContent io.Reader, filename, printerName string) error {
-	queueOnce.Do(startPrintWorker)
-	resultChan := make(chan error, 1)
-	printQueue <- printTask{
-		pdfContent:  pdfContent,
-		filename:    filename,
-		printerName: printerName,
-		resultChan:  resultChan,
+	return enqueue(printTask{pdfContent: pdfContent, filename: filename, printerName: printerName})
+}
+
+// enqueue 把任务加入打印队列并等待结果
+func enqueue(task printTask) error {
+	task.id = newJobID()
+	task.resultChan = make(chan error, 1)
+
+	// 入队和 Shutdown 设置 closing 互斥，Shutdown 取出剩余任务时不会漏掉已经计数的任务
+	queueMu.Lock()
+	if closing {
+		queueMu.Unlock()
+		return ErrClosed
 	}
-	return <-resultChan
+	queueOnce.Do(startPrintWorker)
+	pending.Add(1)
+	notifyJob(task, JobQueued, nil)
+	printQueue <- task
+	queueMu.Unlock()
+
+	return <-task.resultChan
 }
 
 // SavePDFToHistory 保存PDF到history目录，重名自动累加
@@ -0,0 +1,193 @@
+package print
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrClosed 表示打印服务正在关闭，不再接受新任务
+var ErrClosed = errors.New("打印服务正在关闭")
+
+var (
+	queueMu    sync.Mutex
+	closing    bool           // 受 queueMu 保护
+	pending    sync.WaitGroup // 已接受但未结束的任务
+	persisting atomic.Bool    // 关闭期限已到，剩余任务保存到 pending 目录
+)
+
+// pendingJob 是保存到 pending 目录的任务信息，PDF 内容保存在同名的 .pdf 文件中
+type pendingJob struct {
+	Filename string    `json:"filename"`
+	Printer  string    `json:"printer,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Shutdown 不再接受新任务，等待队列中的任务打印完成；ctx 到期时把还没开始的任务保存到 pending 目录，
+// 下次启动时由 ResumePending 继续打印
+func Shutdown(ctx context.Context) error {
+	queueMu.Lock()
+	closing = true
+	queue := printQueue
+	queueMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// 正在打印的任务无法中断，之后取出的任务都改为保存
+	persisting.Store(true)
+	saved := 0
+	for {
+		select {
+		case task := <-queue:
+			persistTask(task)
+			saved++
+		default:
+			return fmt.Errorf("打印队列未在期限内完成，已保存 %d 个任务: %w", saved, ctx.Err())
+		}
+	}
+}
+
+// pendingDir 返回保存未打印任务的目录，测试时替换
+var pendingDir = func() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+	return filepath.Join(filepath.Dir(exePath), "pending"), nil
+}
+
+// persistTask 保存未打印的任务并通知等待结果的请求
+func persistTask(task printTask) {
+	var err error
+	if !task.resumed {
+		err = savePending(task)
+	}
+	if err == nil {
+		err = fmt.Errorf("%w，任务已保存，重启后继续打印", ErrClosed)
+	} else {
+		err = fmt.Errorf("%w，任务保存失败: %v", ErrClosed, err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"module":   "Print",
+		"filename": task.filename,
+		"error":    err,
+	}).Warn("打印任务未执行")
+	task.resultChan <- err
+	close(task.resultChan)
+	notifyJob(task, JobFailed, err)
+	pending.Done()
+}
+
+func savePending(task printTask) error {
+	dir, err := pendingDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建pending目录失败: %w", err)
+	}
+	base := filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	out, err := os.Create(base + ".pdf")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, task.pdfContent)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(base + ".pdf")
+		return err
+	}
+
+	meta, err := json.Marshal(pendingJob{Filename: task.filename, Printer: task.printerName, At: time.Now()})
+	if err != nil {
+		return err
+	}
+	// 最后写入任务信息，只有内容完整的任务才会被恢复
+	return os.WriteFile(base+".json", meta, 0644)
+}
+
+// ResumePending 把上次关闭时保存的任务重新加入打印队列，返回恢复的任务数
+func ResumePending() (int, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		base = filepath.Join(dir, base)
+		job, content, err := loadPending(base)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "Print",
+				"file":   base,
+				"error":  err,
+			}).Error("读取保存的打印任务失败")
+			continue
+		}
+		resumed++
+		go func() {
+			// 打印成功后才删除保存的文件，失败或再次关闭时留到下次启动
+			err := enqueue(printTask{pdfContent: bytes.NewReader(content), filename: job.Filename, printerName: job.Printer, resumed: true})
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"module":   "Print",
+					"filename": job.Filename,
+					"error":    err,
+				}).Error("恢复的打印任务失败")
+				return
+			}
+			os.Remove(base + ".json")
+			os.Remove(base + ".pdf")
+		}()
+	}
+	return resumed, nil
+}
+
+func loadPending(base string) (pendingJob, []byte, error) {
+	var job pendingJob
+	meta, err := os.ReadFile(base + ".json")
+	if err != nil {
+		return job, nil, err
+	}
+	if err := json.Unmarshal(meta, &job); err != nil {
+		return job, nil, err
+	}
+	content, err := os.ReadFile(base + ".pdf")
+	return job, content, err
+}
@@ -0,0 +1,133 @@
+package print
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubPrinter 替换打印工具和 pending 目录，打印的内容按 "文件名:内容" 发送到返回的通道
+func stubPrinter(t *testing.T, print func(filename string) error) (string, <-chan string) {
+	dir := t.TempDir()
+	printed := make(chan string, 4)
+	pendingDir = func() (string, error) { return dir, nil }
+	printFile = func(r io.Reader, filename, printerName string) error {
+		data, _ := io.ReadAll(r)
+		printed <- filename + ":" + string(data)
+		return print(filename)
+	}
+	t.Cleanup(func() {
+		pendingDir, printFile = nil, doPrintPDF
+		SetJobListener(nil)
+	})
+	return dir, printed
+}
+
+func waitEmpty(t *testing.T, dir string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending files left: %v", entries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 关闭会改变包级状态，恢复的测试必须在关闭的测试之前运行
+func TestResumePending(t *testing.T) {
+	fail := errors.New("打印机离线")
+	var failing atomic.Bool
+	failing.Store(true)
+	dir, printed := stubPrinter(t, func(string) error {
+		if failing.Load() {
+			return fail
+		}
+		return nil
+	})
+	if err := savePending(printTask{pdfContent: strings.NewReader("B"), filename: "b.pdf", printerName: "P1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 打印失败时保留文件，下次启动再试
+	if n, err := ResumePending(); n != 1 || err != nil {
+		t.Fatalf("ResumePending() = %d, %v", n, err)
+	}
+	if got := <-printed; got != "b.pdf:B" {
+		t.Fatalf("printed %q", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 2 {
+		t.Fatalf("pending files after failed reprint = %v", files)
+	}
+
+	failing.Store(false)
+	if n, err := ResumePending(); n != 1 || err != nil {
+		t.Fatalf("ResumePending() = %d, %v", n, err)
+	}
+	if got := <-printed; got != "b.pdf:B" {
+		t.Fatalf("printed %q", got)
+	}
+	waitEmpty(t, dir)
+}
+
+func TestShutdownPersistsQueuedJobs(t *testing.T) {
+	release := make(chan struct{})
+	dir, printed := stubPrinter(t, func(string) error {
+		<-release
+		return nil
+	})
+	queued := make(chan string, 4)
+	SetJobListener(func(job Job) {
+		if job.State == JobQueued {
+			queued <- job.Filename
+		}
+	})
+
+	// a.pdf 正在打印，b.pdf 排队
+	results := make(chan error, 2)
+	go func() { results <- PrintPDF(strings.NewReader("A"), "a.pdf", "") }()
+	if got := <-printed; got != "a.pdf:A" {
+		t.Fatalf("printed %q", got)
+	}
+	go func() { results <- PrintPDF(strings.NewReader("B"), "b.pdf", "P1") }()
+	for len(queued) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := <-results; !errors.Is(err, ErrClosed) {
+		t.Fatalf("queued job result = %v, want ErrClosed", err)
+	}
+	if err := PrintPDF(strings.NewReader("C"), "c.pdf", ""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("PrintPDF after Shutdown = %v, want ErrClosed", err)
+	}
+	close(release)
+	if err := <-results; err != nil {
+		t.Fatalf("printing job result = %v", err)
+	}
+
+	// 排队的任务保存到 pending 目录，ResumePending 会在下次启动时读取
+	metas, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(metas) != 1 {
+		t.Fatalf("pending jobs = %v", metas)
+	}
+	job, content, err := loadPending(strings.TrimSuffix(metas[0], ".json"))
+	if err != nil || job.Filename != "b.pdf" || job.Printer != "P1" || string(content) != "B" {
+		t.Fatalf("loadPending() = %+v, %q, %v", job, content, err)
+	}
+}
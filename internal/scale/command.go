@@ -0,0 +1,68 @@
+package scale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Command is an operator action sent to the indicator.
+type Command string
+
+const (
+	CommandZero      Command = "zero"
+	CommandTare      Command = "tare"
+	CommandClearTare Command = "clear_tare"
+	CommandPrint     Command = "print"
+)
+
+// ErrCommandUnsupported is returned when a model has no encoding for a command.
+var ErrCommandUnsupported = errors.New("该型号不支持此指令")
+
+// commandBytes holds the per-model command encodings. The default model
+// follows the common A&D style ASCII command set.
+var commandBytes = map[string]map[Command]string{
+	ModelDefault: {
+		CommandZero:      "Z\r\n",
+		CommandTare:      "T\r\n",
+		CommandClearTare: "CT\r\n",
+		CommandPrint:     "Q\r\n",
+	},
+}
+
+// ParseCommand validates a command name.
+func ParseCommand(name string) (Command, error) {
+	cmd := Command(strings.ToLower(strings.TrimSpace(name)))
+	switch cmd {
+	case CommandZero, CommandTare, CommandClearTare, CommandPrint:
+		return cmd, nil
+	}
+	return "", fmt.Errorf("未知指令 %q", name)
+}
+
+// CommandBytes returns the bytes to write to the indicator for cmd.
+func CommandBytes(model string, cmd Command) ([]byte, error) {
+	model = NormalizeModel(model)
+	encoded, ok := commandBytes[model][cmd]
+	if !ok {
+		return nil, fmt.Errorf("%s 型号发送 %s 失败: %w", model, cmd, ErrCommandUnsupported)
+	}
+	return []byte(encoded), nil
+}
+
+// Confirmation returns a predicate that recognises a reading showing cmd took
+// effect, or nil when the model's output cannot confirm it.
+func Confirmation(model string, cmd Command) func(Reading) bool {
+	switch NormalizeModel(model) {
+	case ModelDefault:
+		switch cmd {
+		case CommandZero:
+			return func(r Reading) bool { return r.Stable && r.Mode == ModeGross && r.Value() == 0 }
+		case CommandTare:
+			return func(r Reading) bool { return r.Mode == ModeNet && r.Value() == 0 }
+		case CommandClearTare:
+			return func(r Reading) bool { return r.Mode == ModeGross }
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package scale
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCommandBytes(t *testing.T) {
+	got, err := CommandBytes(" Default ", CommandZero)
+	if err != nil {
+		t.Fatalf("CommandBytes() error = %v", err)
+	}
+	if string(got) != "Z\r\n" {
+		t.Fatalf("CommandBytes() = %q, want %q", got, "Z\r\n")
+	}
+
+	if _, err := CommandBytes(ModelHEBTW, CommandZero); !errors.Is(err, ErrCommandUnsupported) {
+		t.Fatalf("CommandBytes() error = %v, want ErrCommandUnsupported", err)
+	}
+}
+
+func TestConfirmation(t *testing.T) {
+	tare := Confirmation(ModelDefault, CommandTare)
+	if tare == nil {
+		t.Fatal("Confirmation() should confirm tare for the default model")
+	}
+	if tare(Reading{Weight: "+0.000", Stable: true, Mode: ModeGross}) {
+		t.Fatal("a gross reading should not confirm tare")
+	}
+	if !tare(Reading{Weight: "+0.000", Stable: true, Mode: ModeNet}) {
+		t.Fatal("a zero net reading should confirm tare")
+	}
+
+	if Confirmation(ModelDefault, CommandPrint) != nil {
+		t.Fatal("print cannot be confirmed from readings")
+	}
+}
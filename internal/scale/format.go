@@ -0,0 +1,42 @@
+package scale
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format renders a reading as a frame in the given model's wire format, so
+// normalized readings can be re-emitted to software that expects that model.
+func Format(model string, r Reading) (string, error) {
+	sign, digits := splitSign(r.Weight)
+	switch NormalizeModel(model) {
+	case ModelDefault:
+		stability := "ST"
+		if !r.Stable {
+			stability = "US"
+		}
+		mode := r.Mode
+		if mode == "" {
+			mode = ModeGross
+		}
+		return fmt.Sprintf("%s,%s%s%8s%s\r\n", stability, mode, sign, digits, r.Unit), nil
+	case ModelHEBTW:
+		width := 7 - len(sign)
+		if pad := width - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		return "wn" + sign + digits + r.Unit + "\r\n", nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownModel, model)
+}
+
+// splitSign drops a leading "+" and separates a leading "-".
+func splitSign(weight string) (string, string) {
+	switch {
+	case strings.HasPrefix(weight, "-"):
+		return "-", weight[1:]
+	case strings.HasPrefix(weight, "+"):
+		return "", weight[1:]
+	}
+	return "", weight
+}
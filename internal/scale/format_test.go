@@ -0,0 +1,32 @@
+package scale
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		model   string
+		reading Reading
+		want    string
+	}{
+		{ModelDefault, Reading{Weight: "59.6", Unit: "kg", Stable: true, Mode: ModeGross}, "ST,GS    59.6kg\r\n"},
+		{ModelDefault, Reading{Weight: "-0.0", Unit: "kg", Stable: true, Mode: ModeGross}, "ST,GS-     0.0kg\r\n"},
+		{ModelDefault, Reading{Weight: "+1.234", Unit: "kg", Stable: false, Mode: ModeNet}, "US,NT   1.234kg\r\n"},
+		{ModelHEBTW, Reading{Weight: "2.02", Unit: "kg", Stable: true, Mode: ModeGross}, "wn0002.02kg\r\n"},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.model, tt.reading)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		if got != tt.want {
+			t.Fatalf("Format() = %q, want %q", got, tt.want)
+		}
+		back, err := ParseReading(tt.model, got)
+		if err != nil {
+			t.Fatalf("ParseReading(Format()) error = %v", err)
+		}
+		if back.Value() != tt.reading.Value() || back.Stable != tt.reading.Stable || back.Mode != tt.reading.Mode {
+			t.Fatalf("ParseReading(Format()) = %+v, want %+v", back, tt.reading)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package scale
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -11,7 +13,72 @@ const (
 	ModelHEBTW   = "heb-tw"
 )
 
-var weightPattern = regexp.MustCompile(`^\s*([+-]?)\s*(\d+)(\.\d+)?\s*kg\s*$`)
+const (
+	ModeGross = "GS"
+	ModeNet   = "NT"
+)
+
+// Parse errors, usable with errors.Is to classify failures.
+var (
+	ErrUnknownModel = errors.New("不支持的地磅型号")
+	ErrHeader       = errors.New("报文头不匹配")
+	ErrWeight       = errors.New("重量格式无效")
+	// ErrChecksum is returned for models whose frames carry a checksum;
+	// none of the models supported today do, so it is never returned yet.
+	ErrChecksum = errors.New("校验失败")
+)
+
+// Parse error reasons reported by ErrorReason.
+const (
+	ReasonModel    = "model"
+	ReasonHeader   = "header"
+	ReasonWeight   = "weight"
+	ReasonChecksum = "checksum"
+	ReasonOther    = "other"
+)
+
+// ErrorReason returns a short, stable key describing why parsing failed.
+func ErrorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownModel):
+		return ReasonModel
+	case errors.Is(err, ErrHeader):
+		return ReasonHeader
+	case errors.Is(err, ErrWeight):
+		return ReasonWeight
+	case errors.Is(err, ErrChecksum):
+		return ReasonChecksum
+	}
+	return ReasonOther
+}
+
+var (
+	weightPattern = regexp.MustCompile(`^\s*([+-]?)\s*(\d+)(\.\d+)?\s*kg\s*$`)
+	headerPattern = regexp.MustCompile(`^(ST|US),(GS|NT)`)
+)
+
+// Reading is a parsed indicator frame.
+type Reading struct {
+	Weight string `json:"weight"` // normalized, e.g. "+1.234" or "-2.5"
+	Unit   string `json:"unit"`
+	Stable bool   `json:"stable"`
+	Mode   string `json:"mode"` // ModeGross or ModeNet
+}
+
+// Value returns the weight as a number.
+func (r Reading) Value() float64 {
+	v, _ := strconv.ParseFloat(r.Weight, 64)
+	return v
+}
+
+// Legacy formats the reading in the stable WebSocket text format. Only
+// stable gross readings have a legacy representation.
+func (r Reading) Legacy() (string, bool) {
+	if !r.Stable || r.Mode != ModeGross {
+		return "", false
+	}
+	return "ST,GS     " + r.Weight + r.Unit, true
+}
 
 // NormalizeModel makes model selection insensitive to whitespace and casing.
 func NormalizeModel(model string) string {
@@ -24,36 +91,54 @@ func NormalizeModel(model string) string {
 
 // Parse converts a model-specific frame to the stable WebSocket format.
 func Parse(model, frame string) (string, error) {
+	reading, err := ParseReading(model, frame)
+	if err != nil {
+		return "", err
+	}
+	message, ok := reading.Legacy()
+	if !ok {
+		return "", fmt.Errorf("default 型号报文应以 ST,GS 开头")
+	}
+	return message, nil
+}
+
+// ParseReading converts a model-specific frame to a Reading.
+func ParseReading(model, frame string) (Reading, error) {
 	model = NormalizeModel(model)
 	frame = strings.TrimSpace(frame)
 
+	reading := Reading{Unit: "kg", Stable: true, Mode: ModeGross}
 	var payload string
 	switch model {
 	case ModelDefault:
-		if !strings.HasPrefix(frame, "ST,GS") {
-			return "", fmt.Errorf("default 型号报文应以 ST,GS 开头")
+		header := headerPattern.FindStringSubmatch(frame)
+		if header == nil {
+			return Reading{}, fmt.Errorf("%w: default 型号报文应以 ST/US,GS/NT 开头", ErrHeader)
 		}
-		payload = strings.TrimPrefix(strings.TrimPrefix(frame, "ST,GS"), ",")
+		reading.Stable = header[1] == "ST"
+		reading.Mode = header[2]
+		payload = strings.TrimPrefix(frame[len(header[0]):], ",")
 	case ModelHEBTW:
 		if len(frame) < 2 || !strings.EqualFold(frame[:2], "wn") {
-			return "", fmt.Errorf("heb-tw 型号报文应以 wn 开头")
+			return Reading{}, fmt.Errorf("%w: heb-tw 型号报文应以 wn 开头", ErrHeader)
 		}
 		payload = frame[2:]
 	default:
-		return "", fmt.Errorf("不支持的地磅型号 %q", model)
+		return Reading{}, fmt.Errorf("%w %q", ErrUnknownModel, model)
 	}
 
 	weight, err := normalizeWeight(payload)
 	if err != nil {
-		return "", fmt.Errorf("解析 %s 报文失败: %w", model, err)
+		return Reading{}, fmt.Errorf("解析 %s 报文失败: %w", model, err)
 	}
-	return "ST,GS     " + weight + "kg", nil
+	reading.Weight = weight
+	return reading, nil
 }
 
 func normalizeWeight(payload string) (string, error) {
 	matches := weightPattern.FindStringSubmatch(payload)
 	if matches == nil {
-		return "", fmt.Errorf("重量格式无效: %q", payload)
+		return "", fmt.Errorf("%w: %q", ErrWeight, payload)
 	}
 
 	integer := strings.TrimLeft(matches[2], "0")
@@ -59,3 +59,36 @@ func TestParseRejectsInvalidFrame(t *testing.T) {
 		t.Fatal("Parse() should reject a frame from another model")
 	}
 }
+
+func TestParseReading(t *testing.T) {
+	got, err := ParseReading(ModelDefault, "US,NT,-000.500kg\r\n")
+	if err != nil {
+		t.Fatalf("ParseReading() error = %v", err)
+	}
+	want := Reading{Weight: "-0.500", Unit: "kg", Stable: false, Mode: ModeNet}
+	if got != want {
+		t.Fatalf("ParseReading() = %+v, want %+v", got, want)
+	}
+
+	if _, err := Parse(ModelDefault, "US,NT,-000.500kg"); err == nil {
+		t.Fatal("Parse() should reject readings without a legacy representation")
+	}
+}
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		model string
+		frame string
+		want  string
+	}{
+		{model: "unknown", frame: "ST,GS     1.0kg", want: ReasonModel},
+		{model: ModelDefault, frame: "wn0001.00kg", want: ReasonHeader},
+		{model: ModelDefault, frame: "ST,GS     1.0lb", want: ReasonWeight},
+	}
+	for _, tt := range tests {
+		_, err := ParseReading(tt.model, tt.frame)
+		if got := ErrorReason(err); got != tt.want {
+			t.Errorf("ErrorReason(%q) = %q, want %q", err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandResult 描述一次指令的执行结果
+type CommandResult struct {
+	ScaleID   string        `json:"scaleId"`
+	Command   scale.Command `json:"command"`
+	Confirmed bool          `json:"confirmed"` // 是否通过后续读数确认指令已生效
+	Checkable bool          `json:"checkable"` // 该型号协议能否确认此指令
+	Weight    string        `json:"weight,omitempty"`
+}
+
+// readingState 保存最近一次解析出的读数，并在更新时通知等待者
+type readingState struct {
+	mu         sync.Mutex
+	last       scale.Reading
+	receivedAt time.Time
+	changed    chan struct{}
+}
+
+func (r *readingState) store(reading scale.Reading) {
+	r.mu.Lock()
+	r.last = reading
+	r.receivedAt = time.Now()
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
+
+// reset 清除旧读数，不唤醒等待者
+func (r *readingState) reset() {
+	r.mu.Lock()
+	r.last = scale.Reading{}
+	r.receivedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+func (r *readingState) load() (scale.Reading, time.Time, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last, r.receivedAt, r.changed
+}
+
+// waitFor 等待 since 之后收到满足 match 的读数，返回读数及其接收时间
+func (r *readingState) waitFor(ctx context.Context, since time.Time, match func(scale.Reading) bool) (scale.Reading, time.Time, error) {
+	for {
+		reading, receivedAt, changed := r.load()
+		if receivedAt.After(since) && match(reading) {
+			return reading, receivedAt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return reading, receivedAt, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// LastReading 返回最近一次解析出的读数及其接收时间，尚无读数时 ok 为false
+func (s *SerialManager) LastReading() (reading scale.Reading, receivedAt time.Time, ok bool) {
+	reading, receivedAt, _ = s.reading.load()
+	return reading, receivedAt, !receivedAt.IsZero()
+}
+
+// SendCommand 向仪表写入指令，协议支持时等待后续读数确认指令生效
+func (s *SerialManager) SendCommand(ctx context.Context, cmd scale.Command) (CommandResult, error) {
+	result := CommandResult{ScaleID: s.id, Command: cmd}
+
+	model := s.Model()
+	data, err := scale.CommandBytes(model, cmd)
+	if err != nil {
+		return result, err
+	}
+
+	s.mu.Lock()
+	port := s.port
+	s.mu.Unlock()
+	if port == nil {
+		return result, fmt.Errorf("地磅 %s 端口未打开", s.id)
+	}
+	// 写入可能阻塞，只持有 writeMu，不影响状态查询和断开重连
+	s.writeMu.Lock()
+	sentAt := time.Now()
+	_, err = port.Write(data)
+	s.writeMu.Unlock()
+	if err != nil {
+		return result, fmt.Errorf("写入指令失败: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"module":  "Serial",
+		"scale":   s.id,
+		"command": cmd,
+		"data":    fmt.Sprintf("%q", data),
+	}).Info("发送指令")
+
+	match := scale.Confirmation(model, cmd)
+	if match == nil {
+		return result, nil
+	}
+	result.Checkable = true
+
+	reading, _, err := s.reading.waitFor(ctx, sentAt, match)
+	if err != nil {
+		return result, fmt.Errorf("等待指令 %s 生效超时: %w", cmd, err)
+	}
+	result.Confirmed = true
+	result.Weight = reading.Weight
+	return result, nil
+}
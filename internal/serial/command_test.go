@@ -0,0 +1,57 @@
+package serial
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+)
+
+// blockingPort 的写入阻塞到 release 关闭
+type blockingPort struct {
+	*pipePort
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPort) Write(b []byte) (int, error) {
+	close(p.writing)
+	<-p.release
+	return len(b), nil
+}
+
+func TestSendCommandDoesNotHoldLockWhileWriting(t *testing.T) {
+	m := NewSerialManager("test", Settings{PortName: "COM1", BaudRate: 9600}, time.Second, nil)
+	port := &blockingPort{pipePort: newPipePort(), writing: make(chan struct{}), release: make(chan struct{})}
+	m.port = port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.SendCommand(ctx, scale.CommandZero)
+		done <- err
+	}()
+	<-port.writing
+
+	// 写入阻塞期间仍能读取设置和状态
+	settled := make(chan struct{})
+	go func() {
+		m.Settings()
+		m.Status()
+		close(settled)
+	}()
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		t.Fatal("Settings() blocked by a pending command write")
+	}
+
+	close(port.release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendCommand() did not return after the write finished")
+	}
+}
@@ -0,0 +1,116 @@
+package serial
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// FrameEvent 是一帧原始报文及其解析结果，用于调试新接入的仪表
+type FrameEvent struct {
+	ScaleID string         `json:"scaleId"`
+	At      time.Time      `json:"at"`
+	Hex     string         `json:"hex"`
+	Text    string         `json:"text"` // Go 转义后的文本，控制字符可见
+	Reading *scale.Reading `json:"reading,omitempty"`
+	Legacy  string         `json:"legacy,omitempty"` // 推送给旧版客户端的文本，非稳定毛重时为空
+	Error   string         `json:"error,omitempty"`
+	Reason  string         `json:"reason,omitempty"`
+}
+
+// SubscribeFrames 订阅原始帧及解析结果，返回的取消函数必须调用
+func (s *SerialManager) SubscribeFrames(buffer int) (<-chan FrameEvent, func()) {
+	return s.frames.subscribe(buffer)
+}
+
+// SubscribeRaw 订阅从端口读到的原始字节，返回的取消函数必须调用。
+// 缓冲区满时通道被关闭，订阅者应当视为字节流已中断。
+func (s *SerialManager) SubscribeRaw(buffer int) (<-chan []byte, func()) {
+	return s.raw.subscribe(buffer)
+}
+
+func (s *SerialManager) publishFrame(at time.Time, frame string, reading scale.Reading, err error) {
+	event := FrameEvent{
+		ScaleID: s.id,
+		At:      at,
+		Hex:     hex.EncodeToString([]byte(frame)),
+		Text:    strconv.Quote(frame),
+	}
+	if err != nil {
+		event.Error = err.Error()
+		event.Reason = scale.ErrorReason(err)
+	} else {
+		event.Reading = &reading
+		event.Legacy, _ = reading.Legacy()
+	}
+	s.frames.publish(event)
+}
+
+var debugUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// DebugHandler 处理 /ws/debug?scale=...，实时推送原始帧和解析结果
+func (reg *Registry) DebugHandler(w http.ResponseWriter, r *http.Request) {
+	scaleID := r.URL.Query().Get("scale")
+	m, ok := reg.Get(scaleID)
+	if !ok {
+		http.Error(w, ErrScaleNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	conn, err := debugUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"error":  err,
+		}).Error("调试连接升级失败")
+		return
+	}
+	defer conn.Close()
+
+	frames, cancel := m.SubscribeFrames(100)
+	defer cancel()
+
+	logrus.WithFields(logrus.Fields{
+		"module": "Serial",
+		"scale":  scaleID,
+		"remote": r.RemoteAddr,
+	}).Info("调试客户端连接")
+
+	// 读循环只用于检测客户端断开
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			logrus.WithFields(logrus.Fields{
+				"module": "Serial",
+				"scale":  scaleID,
+			}).Info("调试客户端断开")
+			return
+		case event := <-frames:
+			if err := conn.WriteJSON(event); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"module": "Serial",
+					"error":  err,
+				}).Debug("写入调试帧失败")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package serial
+
+import (
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+)
+
+func TestSubscribeFramesReportsParseResult(t *testing.T) {
+	m := NewSerialManager("test", Settings{
+		PortName: ReplayScheme + writeCapture(t, "ST,GS     1.0kg\nhello\n") + "?interval=10ms",
+	}, time.Hour, nil)
+	frames, cancel := m.SubscribeFrames(10)
+	defer cancel()
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop()
+
+	var events []FrameEvent
+	for len(events) < 2 {
+		select {
+		case event := <-frames:
+			events = append(events, event)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("received %d frames, want 2", len(events))
+		}
+	}
+
+	if events[0].Hex != "53542c47532020202020312e306b670d0a" || events[0].Legacy != "ST,GS     1.0kg" {
+		t.Fatalf("events[0] = %+v", events[0])
+	}
+	if events[1].Text != `"hello\r\n"` || events[1].Reason != scale.ReasonHeader || events[1].Reading != nil {
+		t.Fatalf("events[1] = %+v", events[1])
+	}
+}
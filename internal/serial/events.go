@@ -0,0 +1,48 @@
+package serial
+
+import (
+	"time"
+
+	"reader/internal/scale"
+)
+
+// Update 是推送循环每个周期发出的一次读数
+type Update struct {
+	ScaleID string
+	Reading scale.Reading
+	At      time.Time // 读数的接收时间
+	Legacy  string    // 推送给旧版客户端的文本（最近一次稳定毛重），可能为空
+}
+
+// 事件类型
+const (
+	EventStatus = "status"
+)
+
+// 连接状态事件的 State
+const (
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+)
+
+// Event 是地磅状态变化事件
+type Event struct {
+	Type    string    `json:"type"`
+	ScaleID string    `json:"scaleId"`
+	At      time.Time `json:"at"`
+	State   string    `json:"state,omitempty"`  // 状态事件：connected/disconnected/attached/detached
+	Port    string    `json:"port,omitempty"`   // 状态事件对应的端口
+	Reason  string    `json:"reason,omitempty"` // 断开原因
+}
+
+// SubscribeEvents 订阅状态事件，返回的取消函数必须调用
+func (s *SerialManager) SubscribeEvents(buffer int) (<-chan Event, func()) {
+	return s.events.subscribe(buffer)
+}
+
+func (s *SerialManager) publishStatus(state, port, reason string) {
+	if !s.events.active() {
+		return
+	}
+	s.events.publish(Event{Type: EventStatus, ScaleID: s.id, At: time.Now(), State: state, Port: port, Reason: reason})
+}
@@ -0,0 +1,199 @@
+package serial
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Registry 按ID管理所有地磅数据源
+type Registry struct {
+	mu             sync.RWMutex
+	managers       map[string]*SerialManager
+	commandTimeout time.Duration
+}
+
+func NewRegistry(commandTimeout time.Duration) *Registry {
+	return &Registry{
+		managers:       make(map[string]*SerialManager),
+		commandTimeout: commandTimeout,
+	}
+}
+
+func (reg *Registry) Add(m *SerialManager) {
+	reg.mu.Lock()
+	reg.managers[m.ID()] = m
+	reg.mu.Unlock()
+}
+
+func (reg *Registry) Get(id string) (*SerialManager, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	m, ok := reg.managers[id]
+	return m, ok
+}
+
+// All 按ID顺序返回所有地磅
+func (reg *Registry) All() []*SerialManager {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	managers := make([]*SerialManager, 0, len(reg.managers))
+	for _, m := range reg.managers {
+		managers = append(managers, m)
+	}
+	sort.Slice(managers, func(i, j int) bool { return managers[i].ID() < managers[j].ID() })
+	return managers
+}
+
+// SendCommand 向指定地磅发送指令，超时时间为配置的 command_timeout
+func (reg *Registry) SendCommand(ctx context.Context, scaleID, name string) (CommandResult, error) {
+	m, ok := reg.Get(scaleID)
+	if !ok {
+		return CommandResult{ScaleID: scaleID}, ErrScaleNotFound
+	}
+	cmd, err := scale.ParseCommand(name)
+	if err != nil {
+		return CommandResult{ScaleID: scaleID}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, reg.commandTimeout)
+	defer cancel()
+	return m.SendCommand(ctx, cmd)
+}
+
+// ErrScaleNotFound 表示请求的地磅ID不存在
+var ErrScaleNotFound = errors.New("地磅不存在")
+
+// StatusHandler 处理 GET /scales/{id}/status
+func (reg *Registry) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := reg.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, ErrScaleNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m.Status())
+}
+
+type settingsRequest struct {
+	Port            string    `json:"port"`
+	BaudRate        int       `json:"baudRate"`
+	Model           string    `json:"model"`
+	USB             *USBMatch `json:"usb"`
+	WatchdogTimeout *int      `json:"watchdogTimeout"` // 毫秒
+}
+
+// SettingsHandler 处理 PUT /scales/{id}/settings，只更新请求中出现的字段
+func (reg *Registry) SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := reg.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, ErrScaleNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req settingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 回放会读取本机任意文件并通过共享端口和调试接口输出，只能在配置文件中设置
+	if IsReplay(req.Port) {
+		http.Error(w, "回放数据源只能在配置文件中设置", http.StatusBadRequest)
+		return
+	}
+
+	settings := m.Settings()
+	if req.Port != "" {
+		settings.PortName = req.Port
+	}
+	if req.BaudRate != 0 {
+		settings.BaudRate = req.BaudRate
+	}
+	if req.Model != "" {
+		settings.Model = req.Model
+	}
+	if req.USB != nil {
+		settings.USB = *req.USB
+	}
+	if req.WatchdogTimeout != nil {
+		settings.WatchdogTimeout = time.Duration(*req.WatchdogTimeout) * time.Millisecond
+	}
+
+	if err := m.Reconfigure(settings); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"scale":  m.ID(),
+			"error":  err,
+		}).Error("更新连接参数失败")
+		http.Error(w, "更新连接参数失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m.Status())
+}
+
+type commandRequest struct {
+	Command string `json:"command"`
+}
+
+// CommandHandler 处理 POST /scales/{id}/commands
+func (reg *Registry) CommandHandler(w http.ResponseWriter, r *http.Request) {
+	scaleID := mux.Vars(r)["id"]
+	var req commandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := reg.SendCommand(r.Context(), scaleID, req.Command)
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, ErrScaleNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, scale.ErrCommandUnsupported):
+		status = http.StatusNotImplemented
+	case err != nil && result.Command == "":
+		status = http.StatusBadRequest
+	case err != nil:
+		status = http.StatusBadGateway
+	case !result.Checkable:
+		status = http.StatusAccepted // 已发送，但协议无法确认是否生效
+	}
+
+	response := map[string]interface{}{
+		"success": err == nil,
+		"result":  result,
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module":  "Serial",
+			"scale":   scaleID,
+			"command": req.Command,
+			"error":   err,
+		}).Warn("指令执行失败")
+		response["message"] = err.Error()
+	}
+
+	writeJSON(w, status, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"error":  err,
+		}).Error("响应编码失败")
+	}
+}
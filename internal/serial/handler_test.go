@@ -0,0 +1,27 @@
+package serial
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSettingsHandlerRejectsReplay(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	reg.Add(NewSerialManager("a", Settings{PortName: "COM1"}, time.Hour, nil))
+	r := mux.NewRouter()
+	r.HandleFunc("/scales/{id}/settings", reg.SettingsHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/scales/a/settings", strings.NewReader(`{"port":"replay:///etc/passwd"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT settings with replay port = %d, want 400", w.Code)
+	}
+	if got := reg.All()[0].Settings().PortName; got != "COM1" {
+		t.Fatalf("PortName = %q, want COM1", got)
+	}
+}
@@ -0,0 +1,167 @@
+package serial
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.bug.st/serial/enumerator"
+)
+
+// USBMatch 按 USB 信息识别串口适配器，重新插拔后端口号变化（如 COM3 变成 COM4）也能找到设备。
+// 为空的字段不参与匹配。
+type USBMatch struct {
+	VID          string `json:"vid,omitempty"`
+	PID          string `json:"pid,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+}
+
+func (m USBMatch) IsZero() bool {
+	return m.VID == "" && m.PID == "" && m.SerialNumber == ""
+}
+
+func (m USBMatch) matches(p *enumerator.PortDetails) bool {
+	if !p.IsUSB {
+		return false
+	}
+	return (m.VID == "" || strings.EqualFold(m.VID, p.VID)) &&
+		(m.PID == "" || strings.EqualFold(m.PID, p.PID)) &&
+		(m.SerialNumber == "" || m.SerialNumber == p.SerialNumber)
+}
+
+const (
+	HotplugAttached = "attached"
+	HotplugDetached = "detached"
+)
+
+// HotplugEvent 记录一次设备插入或拔出
+type HotplugEvent struct {
+	Type string    `json:"type"`
+	Port string    `json:"port"`
+	At   time.Time `json:"at"`
+}
+
+// listPorts 枚举系统串口，测试时可替换
+var listPorts = enumerator.GetDetailedPortsList
+
+// findDevice 返回配置的设备当前对应的端口名，未找到时返回空字符串
+func findDevice(ports []*enumerator.PortDetails, settings Settings) string {
+	for _, p := range ports {
+		if !settings.USB.IsZero() {
+			if settings.USB.matches(p) {
+				return p.Name
+			}
+			continue
+		}
+		if strings.EqualFold(p.Name, settings.PortName) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// hotplugLoop 定期枚举串口，设备重新出现时立即唤醒处于退避等待中的重连
+func (s *SerialManager) hotplugLoop(ctx context.Context) {
+	settings := s.Settings()
+	if settings.HotplugInterval <= 0 || IsReplay(settings.PortName) {
+		return
+	}
+
+	ticker := time.NewTicker(settings.HotplugInterval)
+	defer ticker.Stop()
+
+	present, initialized := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ports, err := listPorts()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "Serial",
+				"scale":  s.id,
+				"error":  err,
+			}).Debug("枚举串口失败")
+			continue
+		}
+
+		settings = s.Settings()
+		port := findDevice(ports, settings)
+		if !initialized {
+			present, initialized = port != "", true
+			continue
+		}
+
+		switch {
+		case port != "" && !present:
+			present = true
+			s.onDeviceAttached(port, settings)
+		case port == "" && present:
+			present = false
+			s.onDeviceDetached(settings)
+		}
+	}
+}
+
+func (s *SerialManager) onDeviceAttached(port string, settings Settings) {
+	s.status.recordHotplug(HotplugEvent{Type: HotplugAttached, Port: port, At: time.Now()})
+	s.publishStatus(HotplugAttached, port, "")
+	logrus.WithFields(logrus.Fields{
+		"module": "Serial",
+		"scale":  s.id,
+		"port":   port,
+	}).Info("检测到设备插入，立即重新连接")
+
+	if port != settings.PortName {
+		// 按 USB 信息匹配到的设备换了端口号
+		s.mu.Lock()
+		s.settings.PortName = port
+		s.mu.Unlock()
+		logrus.WithFields(logrus.Fields{
+			"module":  "Serial",
+			"scale":   s.id,
+			"oldPort": settings.PortName,
+			"port":    port,
+		}).Info("设备端口号已变化")
+	}
+
+	select {
+	case s.replugged <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SerialManager) onDeviceDetached(settings Settings) {
+	s.status.recordHotplug(HotplugEvent{Type: HotplugDetached, Port: settings.PortName, At: time.Now()})
+	s.publishStatus(HotplugDetached, settings.PortName, "")
+	logrus.WithFields(logrus.Fields{
+		"module": "Serial",
+		"scale":  s.id,
+		"port":   settings.PortName,
+	}).Warn("检测到设备拔出")
+
+	// 主动关闭端口，不必等到读取出错或看门狗超时
+	s.mu.Lock()
+	if s.port != nil {
+		s.port.Close()
+	}
+	s.mu.Unlock()
+}
+
+// waitRetry 退避等待，设备重新插入或收到停止信号时提前返回；停止时返回false
+func (s *SerialManager) waitRetry(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-s.replugged:
+		return true
+	case <-timer.C:
+		return true
+	}
+}
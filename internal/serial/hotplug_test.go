@@ -0,0 +1,115 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
+)
+
+func TestFindDevice(t *testing.T) {
+	ports := []*enumerator.PortDetails{
+		{Name: "COM1"},
+		{Name: "COM4", IsUSB: true, VID: "1A86", PID: "7523", SerialNumber: "A1"},
+	}
+
+	if got := findDevice(ports, Settings{PortName: "com1"}); got != "COM1" {
+		t.Fatalf("findDevice() by name = %q, want COM1", got)
+	}
+	if got := findDevice(ports, Settings{PortName: "COM3", USB: USBMatch{VID: "1a86", PID: "7523"}}); got != "COM4" {
+		t.Fatalf("findDevice() by VID/PID = %q, want COM4", got)
+	}
+	if got := findDevice(ports, Settings{PortName: "COM4", USB: USBMatch{SerialNumber: "B2"}}); got != "" {
+		t.Fatalf("findDevice() with another serial number = %q, want empty", got)
+	}
+}
+
+// pipePort 是没有数据的串口，读取阻塞到 Close
+type pipePort struct {
+	*io.PipeReader
+	w *io.PipeWriter
+}
+
+func newPipePort() *pipePort {
+	r, w := io.Pipe()
+	return &pipePort{PipeReader: r, w: w}
+}
+
+func (p *pipePort) Write(b []byte) (int, error) { return len(b), nil }
+func (p *pipePort) Close() error                { return p.w.Close() }
+
+func TestHotplugFollowsRenamedDevice(t *testing.T) {
+	var mu sync.Mutex
+	var current []*enumerator.PortDetails
+	setPorts := func(ports ...*enumerator.PortDetails) {
+		mu.Lock()
+		current = ports
+		mu.Unlock()
+	}
+	listPorts = func() ([]*enumerator.PortDetails, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return current, nil
+	}
+	defer func() { listPorts = enumerator.GetDetailedPortsList }()
+
+	// 只有当前枚举到的端口能打开，记录每次打开的端口名
+	opened := make(chan string, 16)
+	origOpen := openSerial
+	defer func() { openSerial = origOpen }()
+	openSerial = func(name string, _ *serial.Mode) (io.ReadWriteCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range current {
+			if p.Name == name {
+				opened <- name
+				return newPipePort(), nil
+			}
+		}
+		return nil, errors.New("no such port")
+	}
+
+	adapter := USBMatch{VID: "1A86", PID: "7523"}
+	setPorts(&enumerator.PortDetails{Name: "COM3", IsUSB: true, VID: "1A86", PID: "7523"})
+	m := NewSerialManager("test", Settings{
+		PortName:        "COM3",
+		BaudRate:        9600,
+		USB:             adapter,
+		HotplugInterval: 10 * time.Millisecond,
+	}, time.Hour, nil)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	waitOpened := func(want string) {
+		t.Helper()
+		select {
+		case got := <-opened:
+			if got != want {
+				t.Fatalf("opened %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s was not opened", want)
+		}
+	}
+	waitOpened("COM3")
+
+	time.Sleep(50 * time.Millisecond)
+	setPorts()
+	time.Sleep(50 * time.Millisecond)
+	setPorts(&enumerator.PortDetails{Name: "COM4", IsUSB: true, VID: "1A86", PID: "7523"})
+
+	// 重试等待被插入事件打断，用新端口号重新打开
+	waitOpened("COM4")
+	st := m.Status()
+	if st.Port != "COM4" {
+		t.Fatalf("Status().Port = %q, want COM4", st.Port)
+	}
+	if st.HotplugEvents != 2 || st.LastHotplug == nil || st.LastHotplug.Type != HotplugAttached {
+		t.Fatalf("Status() hotplug = %d %+v", st.HotplugEvents, st.LastHotplug)
+	}
+}
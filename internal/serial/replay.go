@@ -0,0 +1,243 @@
+package serial
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayScheme 是回放数据源的端口前缀，例如 replay://captures/entry.txt?speed=2&loop=true
+const ReplayScheme = "replay://"
+
+// 带时间戳的抓包格式每行一帧：RFC3339 时间戳 + 制表符 + Go 转义的原始字节，例如
+//
+//	2025-07-25T10:00:00.120+08:00	"ST,GS     0.0kg\r\n"
+//
+// 不符合该格式的文件按纯文本转储处理（如 测试数据.txt），每行一帧，按 interval 等间隔回放。
+const captureTimeLayout = time.RFC3339Nano
+
+type replayOptions struct {
+	path     string
+	speed    float64       // 回放速度倍率，2 表示两倍速
+	loop     bool          // 播放结束后是否从头循环
+	offset   time.Duration // 从抓包的第几秒开始回放
+	interval time.Duration // 纯文本转储的帧间隔
+}
+
+type replayEntry struct {
+	at   time.Duration // 相对第一帧的时间
+	data []byte
+}
+
+// IsReplay 判断端口配置是否为回放数据源
+func IsReplay(portName string) bool {
+	return strings.HasPrefix(portName, ReplayScheme)
+}
+
+func parseReplaySpec(spec string) (replayOptions, error) {
+	opts := replayOptions{speed: 1, interval: 500 * time.Millisecond}
+
+	rest := strings.TrimPrefix(spec, ReplayScheme)
+	path, rawQuery := rest, ""
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		path, rawQuery = rest[:i], rest[i+1:]
+	}
+	if path == "" {
+		return opts, fmt.Errorf("回放源缺少文件路径: %q", spec)
+	}
+	opts.path = path
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return opts, fmt.Errorf("回放参数无效: %w", err)
+	}
+	if v := query.Get("speed"); v != "" {
+		speed, err := strconv.ParseFloat(v, 64)
+		if err != nil || speed <= 0 {
+			return opts, fmt.Errorf("回放速度无效: %q", v)
+		}
+		opts.speed = speed
+	}
+	if v := query.Get("loop"); v != "" {
+		loop, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("loop 参数无效: %q", v)
+		}
+		opts.loop = loop
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := time.ParseDuration(v)
+		if err != nil || offset < 0 {
+			return opts, fmt.Errorf("offset 参数无效: %q", v)
+		}
+		opts.offset = offset
+	}
+	if v := query.Get("interval"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return opts, fmt.Errorf("interval 参数无效: %q", v)
+		}
+		opts.interval = interval
+	}
+	return opts, nil
+}
+
+// parseReplay 读取抓包内容，根据第一行自动识别带时间戳格式或纯文本转储
+func parseReplay(r io.Reader, interval time.Duration) ([]replayEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		entries     []replayEntry
+		first       time.Time
+		timestamped bool
+		detected    bool
+		lineNo      int
+	)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		ts, data, ok := parseCaptureLine(line)
+		if !detected {
+			timestamped, detected = ok, true
+			first = ts
+		}
+
+		if !timestamped {
+			entries = append(entries, replayEntry{
+				at:   time.Duration(len(entries)) * interval,
+				data: []byte(line + "\r\n"),
+			})
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("第 %d 行不是有效的抓包记录", lineNo)
+		}
+		at := ts.Sub(first)
+		if at < 0 {
+			return nil, fmt.Errorf("第 %d 行时间戳早于第一帧", lineNo)
+		}
+		entries = append(entries, replayEntry{at: at, data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("抓包文件为空")
+	}
+	return entries, nil
+}
+
+func parseCaptureLine(line string) (time.Time, []byte, bool) {
+	stamp, quoted, found := strings.Cut(line, "\t")
+	if !found {
+		return time.Time{}, nil, false
+	}
+	ts, err := time.Parse(captureTimeLayout, stamp)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	data, err := strconv.Unquote(quoted)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return ts, []byte(data), true
+}
+
+// errReplayEnd 表示不循环的回放已经播放完毕
+var errReplayEnd = errors.New("回放结束")
+
+// replayPort 按抓包中的原始时间间隔输出数据，对读取方表现得和串口一样；不循环时播放完毕返回 io.EOF
+type replayPort struct {
+	reader    *io.PipeReader
+	writer    *io.PipeWriter
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func openReplay(spec string) (*replayPort, error) {
+	opts, err := parseReplaySpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(opts.path)
+	if err != nil {
+		return nil, fmt.Errorf("打开回放文件失败: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := parseReplay(file, opts.interval)
+	if err != nil {
+		return nil, fmt.Errorf("解析回放文件 %s 失败: %w", opts.path, err)
+	}
+	if last := entries[len(entries)-1].at; opts.offset > last {
+		return nil, fmt.Errorf("回放起始偏移 %v 超出抓包时长 %v", opts.offset, last)
+	}
+
+	p := newReplayPort()
+	go p.run(entries, opts)
+	return p, nil
+}
+
+func newReplayPort() *replayPort {
+	pr, pw := io.Pipe()
+	return &replayPort{reader: pr, writer: pw, done: make(chan struct{})}
+}
+
+func (p *replayPort) run(entries []replayEntry, opts replayOptions) {
+	for {
+		start := time.Now()
+		for _, entry := range entries {
+			if entry.at < opts.offset {
+				continue
+			}
+			due := time.Duration(float64(entry.at-opts.offset) / opts.speed)
+			if wait := due - time.Since(start); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-p.done:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			if _, err := p.writer.Write(entry.data); err != nil {
+				return
+			}
+		}
+		if !opts.loop {
+			// 读取方读完剩余数据后收到 io.EOF，停止读取而不是等看门狗重新打开
+			p.writer.Close()
+			return
+		}
+	}
+}
+
+func (p *replayPort) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// Write 丢弃写入的数据，回放源没有可接收指令的设备
+func (p *replayPort) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (p *replayPort) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.writer.Close()
+		p.reader.Close()
+	})
+	return nil
+}
@@ -0,0 +1,107 @@
+package serial
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReplaySpec(t *testing.T) {
+	opts, err := parseReplaySpec("replay://captures/entry.txt?speed=2&loop=true&offset=1.5s")
+	if err != nil {
+		t.Fatalf("parseReplaySpec() error = %v", err)
+	}
+	if opts.path != "captures/entry.txt" || opts.speed != 2 || !opts.loop || opts.offset != 1500*time.Millisecond {
+		t.Fatalf("parseReplaySpec() = %+v", opts)
+	}
+
+	if _, err := parseReplaySpec("replay://a.txt?speed=0"); err == nil {
+		t.Fatal("parseReplaySpec() should reject a zero speed")
+	}
+}
+
+func TestParseReplayPlainDump(t *testing.T) {
+	entries, err := parseReplay(strings.NewReader("ST,GS     0.0kg\r\n\nST,GS    59.6kg"), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("parseReplay() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[1].at != 200*time.Millisecond || string(entries[1].data) != "ST,GS    59.6kg\r\n" {
+		t.Fatalf("entries[1] = %+v", entries[1])
+	}
+}
+
+func TestParseReplayCapture(t *testing.T) {
+	capture := "2025-07-25T10:00:00.100+08:00\t\"wn0000.00kg\\r\\n\"\n" +
+		"2025-07-25T10:00:00.350+08:00\t\"wn0002.02kg\\r\\n\"\n"
+	entries, err := parseReplay(strings.NewReader(capture), time.Second)
+	if err != nil {
+		t.Fatalf("parseReplay() error = %v", err)
+	}
+	if entries[1].at != 250*time.Millisecond || string(entries[1].data) != "wn0002.02kg\r\n" {
+		t.Fatalf("entries[1] = %+v", entries[1])
+	}
+}
+
+func TestReplayPortHonoursOffsetAndSpeed(t *testing.T) {
+	entries := []replayEntry{
+		{at: 0, data: []byte("ST,GS     0.0kg\r\n")},
+		{at: 10 * time.Second, data: []byte("ST,GS    59.6kg\r\n")},
+		{at: 10*time.Second + 100*time.Millisecond, data: []byte("ST,GS    60.0kg\r\n")},
+	}
+	p := newReplayPort()
+	defer p.Close()
+	go p.run(entries, replayOptions{speed: 2, offset: 10 * time.Second})
+
+	reader := bufio.NewReader(p)
+	start := time.Now()
+	for _, want := range []string{"ST,GS    59.6kg\r\n", "ST,GS    60.0kg\r\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString() error = %v", err)
+		}
+		if line != want {
+			t.Fatalf("ReadString() = %q, want %q", line, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("replay took %v, want about 50ms", elapsed)
+	}
+}
+
+func TestReplayStopsAtEndWithWatchdog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry.txt")
+	if err := os.WriteFile(path, []byte("ST,GS     1.0kg\nST,GS     2.0kg\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 看门狗保持配置默认的 10 秒：回放结束后读取循环应当退出，而不是等看门狗重新打开、从头再放
+	m := NewSerialManager("test", Settings{
+		PortName:        ReplayScheme + path + "?interval=10ms",
+		BaudRate:        9600,
+		WatchdogTimeout: 10 * time.Second,
+	}, time.Hour, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		m.readLoop(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("read loop kept running after the replay ended")
+	}
+
+	st := m.Status()
+	if st.Connected || st.Reconnects != 0 || st.Stats.FramesIn != 2 {
+		t.Fatalf("Status() connected = %v, reconnects = %d, frames = %d", st.Connected, st.Reconnects, st.Stats.FramesIn)
+	}
+}
@@ -3,7 +3,9 @@ package serial
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,142 +16,379 @@ import (
 	"go.bug.st/serial"
 )
 
+// Settings 是地磅数据源的连接参数，可通过 Reconfigure 在运行时切换
+type Settings struct {
+	PortName        string        `json:"port"`
+	BaudRate        int           `json:"baudRate"`
+	Model           string        `json:"model"`
+	USB             USBMatch      `json:"usb"`
+	WatchdogTimeout time.Duration `json:"-"`
+	HotplugInterval time.Duration `json:"-"` // 枚举串口的间隔，0 表示不检测热插拔
+}
+
+// Validate 检查连接参数是否可用
+func (st Settings) Validate() error {
+	if st.PortName == "" {
+		return fmt.Errorf("端口不能为空")
+	}
+	if !IsReplay(st.PortName) && st.BaudRate <= 0 {
+		return fmt.Errorf("波特率无效: %d", st.BaudRate)
+	}
+	return nil
+}
+
 type SerialManager struct {
-	ctx               context.Context
-	cancel            context.CancelFunc
+	id                string
 	lastMessage       atomic.Value
-	mu                sync.Mutex
-	port              serial.Port
-	portName          string
-	baudRate          int
-	scaleModel        string
-	onMessage         func(string)
+	mu                sync.Mutex // 保护 port 和 settings
+	port              io.ReadWriteCloser
+	writeMu           sync.Mutex // 串行化指令写入，写入时不持有 mu
+	settings          Settings
+	onMessage         func(Update)
+	reading           readingState
+	status            connStatus
+	stats             frameStats
+	frames            tap[FrameEvent]
+	raw               tap[[]byte]
+	events            tap[Event]
 	retryCount        int
 	maxRetries        int
 	retryInterval     time.Duration
 	broadcastInterval time.Duration
+	replugged         chan struct{} // 设备重新插入时唤醒退避等待
+
+	lifecycle sync.Mutex // 串行化 Start/Stop/Reconfigure
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
 }
 
-func NewSerialManager(port string, baud int, scaleModel string, broadcastInterval time.Duration, onMessage func(string)) *SerialManager {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewSerialManager(id string, settings Settings, broadcastInterval time.Duration, onMessage func(Update)) *SerialManager {
+	settings.Model = scale.NormalizeModel(settings.Model)
 	mgr := &SerialManager{
-		ctx:               ctx,
-		cancel:            cancel,
-		portName:          port,
-		baudRate:          baud,
-		scaleModel:        scale.NormalizeModel(scaleModel),
+		id:                id,
+		settings:          settings,
 		onMessage:         onMessage,
 		retryCount:        0,
 		maxRetries:        10,
 		retryInterval:     5 * time.Second,
 		broadcastInterval: broadcastInterval,
+		replugged:         make(chan struct{}, 1),
 	}
+	mgr.raw.lossless = true
 	mgr.lastMessage.Store("")
+	mgr.reading.changed = make(chan struct{})
 	return mgr
 }
 
-func (s *SerialManager) Start() {
-	go s.readLoop()
-	go s.pushLoop()
+// ID 返回地磅ID
+func (s *SerialManager) ID() string {
+	return s.id
+}
+
+// Settings 返回当前连接参数
+func (s *SerialManager) Settings() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// Model 返回地磅型号
+func (s *SerialManager) Model() string {
+	return s.Settings().Model
+}
+
+// Start 启动读取和推送循环，已在运行时直接返回
+func (s *SerialManager) Start() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	return s.start()
+}
+
+// Stop 停止读取和推送循环，并等待它们退出；未运行时直接返回
+func (s *SerialManager) Stop() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	return s.stop()
+}
+
+// Restart 停止后重新启动
+func (s *SerialManager) Restart() error {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	if err := s.stop(); err != nil {
+		return err
+	}
+	return s.start()
+}
+
+// Reconfigure 切换端口、波特率等连接参数；正在运行时会以新参数重启
+func (s *SerialManager) Reconfigure(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	settings.Model = scale.NormalizeModel(settings.Model)
+
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+
+	running := s.cancel != nil
+	if err := s.stop(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.settings
+	s.settings = settings
+	s.mu.Unlock()
+	s.lastMessage.Store("") // 旧端口的读数不再有效
+	s.reading.reset()
+
+	logrus.WithFields(logrus.Fields{
+		"module":      "Serial",
+		"scale":       s.id,
+		"oldPort":     old.PortName,
+		"port":        settings.PortName,
+		"oldBaudRate": old.BaudRate,
+		"baudRate":    settings.BaudRate,
+	}).Info("更新连接参数")
+
+	if !running {
+		return nil
+	}
+	return s.start()
+}
+
+func (s *SerialManager) start() error {
+	if s.cancel != nil {
+		return nil
+	}
+	if err := s.Settings().Validate(); err != nil {
+		return fmt.Errorf("地磅 %s 配置无效: %w", s.id, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.retryCount = 0
+	s.wg.Add(3)
+	go func() {
+		defer s.wg.Done()
+		s.readLoop(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.pushLoop(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.hotplugLoop(ctx)
+	}()
+	return nil
 }
 
-func (s *SerialManager) Stop() {
+func (s *SerialManager) stop() error {
+	if s.cancel == nil {
+		return nil
+	}
 	s.cancel()
-	if s.port != nil {
-		s.port.Close()
+	s.cancel = nil
+
+	// 关闭端口以打断阻塞中的读取
+	s.mu.Lock()
+	port := s.port
+	s.port = nil
+	s.mu.Unlock()
+	var err error
+	if port != nil {
+		err = port.Close()
 	}
+
+	s.wg.Wait()
+	return err
 }
 
-func (s *SerialManager) readLoop() {
+func (s *SerialManager) readLoop(ctx context.Context) {
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			logrus.WithField("module", "Serial").Info("接收到停止信号，退出读取循环")
 			return
 		default:
 		}
 
-		port, err := s.openPortWithRetry()
+		port, settings, err := s.openPortWithRetry(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
 			logrus.WithFields(logrus.Fields{
 				"module": "Serial",
 				"error":  err,
 			}).Error("端口打开失败，达到最大重试次数")
-			time.Sleep(30 * time.Second) // 长时间等待后重试
-			s.retryCount = 0             // 重置重试计数
+			s.waitRetry(ctx, 30*time.Second) // 长时间等待后重试
+			s.retryCount = 0                 // 重置重试计数
 			continue
 		}
 
 		s.mu.Lock()
+		if ctx.Err() != nil {
+			// Stop 已经执行，不再登记新端口
+			s.mu.Unlock()
+			port.Close()
+			continue
+		}
 		s.port = port
 		s.mu.Unlock()
 
 		logrus.WithFields(logrus.Fields{
 			"module":   "Serial",
-			"port":     s.portName,
-			"baudRate": s.baudRate,
+			"scale":    s.id,
+			"port":     settings.PortName,
+			"baudRate": settings.BaudRate,
 		}).Info("端口打开成功")
 		s.retryCount = 0 // 成功后重置重试计数
+		s.status.setConnected(true)
+		s.publishStatus(StateConnected, settings.PortName, "")
 
-		reader := bufio.NewReader(port)
-		for {
+		err = s.readPort(ctx, port, settings)
+		port.Close()
+		s.mu.Lock()
+		if s.port == port {
+			s.port = nil
+		}
+		s.mu.Unlock()
+		s.status.setConnected(false)
+		if err == nil {
+			s.publishStatus(StateDisconnected, settings.PortName, "")
+			logrus.WithFields(logrus.Fields{
+				"module": "Serial",
+				"port":   settings.PortName,
+			}).Info("关闭端口")
+			return
+		}
+		s.publishStatus(StateDisconnected, settings.PortName, err.Error())
+		if errors.Is(err, errReplayEnd) {
+			logrus.WithFields(logrus.Fields{
+				"module": "Serial",
+				"scale":  s.id,
+				"port":   settings.PortName,
+			}).Info("回放结束，停止读取")
+			return
+		}
+
+		s.status.recordReconnect(err.Error(), errors.Is(err, errWatchdog))
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"scale":  s.id,
+			"error":  err,
+		}).Error("读取中断，重新打开端口")
+
+		if errors.Is(err, errWatchdog) {
+			// 连续无数据时逐步拉长重开间隔，避免反复开关失效的适配器
+			delay := s.retryInterval * time.Duration(s.status.silentStreak.Load())
+			if delay > 30*time.Second {
+				delay = 30 * time.Second
+			}
+			s.waitRetry(ctx, delay)
+		}
+	}
+}
+
+// readPort 持续读取并解析报文，直到读取出错或收到停止信号（返回nil）
+func (s *SerialManager) readPort(ctx context.Context, port io.ReadWriteCloser, settings Settings) error {
+	stopWatchdog, fired := s.startWatchdog(port, settings)
+	defer stopWatchdog()
+
+	reader := bufio.NewReader(&activityReader{r: port, onRead: func(p []byte) {
+		s.status.touch()
+		s.stats.addBytes(len(p))
+		if s.raw.active() {
+			s.raw.publish(append([]byte(nil), p...))
+		}
+	}})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
 			select {
-			case <-s.ctx.Done():
-				port.Close()
-				logrus.WithFields(logrus.Fields{
-					"module": "Serial",
-					"port":   s.portName,
-				}).Info("关闭端口")
-				return
+			case <-ctx.Done():
+				return nil
+			case <-fired:
+				return fmt.Errorf("%w: 超过 %v 未收到数据", errWatchdog, settings.WatchdogTimeout)
 			default:
-				line, err := reader.ReadBytes('\n')
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"module": "Serial",
-						"error":  err,
-					}).Error("读取错误，重新打开端口")
-					port.Close()
-					break // 重新打开串口
-				}
-				readData := string(line)
-				if readData == "" {
-					continue
-				}
-				message, err := scale.Parse(s.scaleModel, readData)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"module": "Serial",
-						"model":  s.scaleModel,
-						"data":   fmt.Sprintf("%q", readData),
-						"error":  err,
-					}).Debug("忽略无法解析的报文")
-					continue
-				}
-				s.lastMessage.Store(message)
-				// 数据接收用Debug级别，不会输出到文件日志
-				logrus.WithFields(logrus.Fields{
-					"module": "Serial",
-					"data":   message,
-				}).Info("接收重量")
 			}
+			if errors.Is(err, io.EOF) && IsReplay(settings.PortName) {
+				return errReplayEnd
+			}
+			return fmt.Errorf("读取错误: %w", err)
 		}
+		readData := string(line)
+		if readData == "" {
+			continue
+		}
+		now := time.Now()
+		s.stats.frameIn()
+		reading, err := scale.ParseReading(settings.Model, readData)
+		if s.frames.active() {
+			s.publishFrame(now, readData, reading, err)
+		}
+		if err != nil {
+			s.stats.parseError(now, readData, err)
+			logrus.WithFields(logrus.Fields{
+				"module": "Serial",
+				"model":  settings.Model,
+				"data":   fmt.Sprintf("%q", readData),
+				"error":  err,
+			}).Debug("忽略无法解析的报文")
+			continue
+		}
+		s.stats.frameParsed(now)
+		s.reading.store(reading)
+		message, ok := reading.Legacy()
+		if !ok {
+			continue // 非稳定毛重报文只用于指令确认，不推送给旧版客户端
+		}
+		s.lastMessage.Store(message)
+		// 数据接收用Debug级别，不会输出到文件日志
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"data":   message,
+		}).Info("接收重量")
 	}
 }
 
-// openPortWithRetry 尝试打开串口，带有退避重试机制
-func (s *SerialManager) openPortWithRetry() (serial.Port, error) {
-	mode := &serial.Mode{BaudRate: s.baudRate}
+// openSerial 打开物理串口，测试时可替换
+var openSerial = func(name string, mode *serial.Mode) (io.ReadWriteCloser, error) {
+	return serial.Open(name, mode)
+}
 
+// openPort 根据端口配置打开串口或回放数据源
+func openPort(settings Settings) (io.ReadWriteCloser, error) {
+	if IsReplay(settings.PortName) {
+		return openReplay(settings.PortName)
+	}
+	return openSerial(settings.PortName, &serial.Mode{BaudRate: settings.BaudRate})
+}
+
+// openPortWithRetry 尝试打开串口，带有退避重试机制，等待期间响应停止信号和设备插入
+func (s *SerialManager) openPortWithRetry(ctx context.Context) (io.ReadWriteCloser, Settings, error) {
+	settings := s.Settings()
 	for s.retryCount < s.maxRetries {
 		select {
-		case <-s.ctx.Done():
-			return nil, fmt.Errorf("操作已取消")
+		case <-ctx.Done():
+			return nil, settings, fmt.Errorf("操作已取消")
 		default:
 		}
 
-		port, err := serial.Open(s.portName, mode)
+		// 每次重试都重新读取配置，设备重新插入后端口号可能已经变化
+		settings = s.Settings()
+		port, err := openPort(settings)
 		if err == nil {
-			return port, nil
+			return port, settings, nil
 		}
 
 		s.retryCount++
@@ -161,19 +400,22 @@ func (s *SerialManager) openPortWithRetry() (serial.Port, error) {
 
 		logrus.WithFields(logrus.Fields{
 			"module":     "Serial",
+			"port":       settings.PortName,
 			"attempt":    s.retryCount,
 			"maxRetries": s.maxRetries,
 			"error":      err,
 			"retryDelay": retryDelay,
 		}).Error("打开端口失败")
 
-		time.Sleep(retryDelay)
+		if !s.waitRetry(ctx, retryDelay) {
+			return nil, settings, fmt.Errorf("操作已取消")
+		}
 	}
 
-	return nil, fmt.Errorf("达到最大重试次数 %d，最后错误: 无法打开串口 %s", s.maxRetries, s.portName)
+	return nil, settings, fmt.Errorf("达到最大重试次数 %d，最后错误: 无法打开串口 %s", s.maxRetries, settings.PortName)
 }
 
-func (s *SerialManager) pushLoop() {
+func (s *SerialManager) pushLoop(ctx context.Context) {
 	ticker := time.NewTicker(s.broadcastInterval)
 	defer ticker.Stop()
 	logrus.WithFields(logrus.Fields{
@@ -183,14 +425,16 @@ func (s *SerialManager) pushLoop() {
 
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-ctx.Done():
 			logrus.WithField("module", "Serial").Info("数据推送循环退出")
 			return
 		case <-ticker.C:
-			val := s.lastMessage.Load()
-			if msg, ok := val.(string); ok && msg != "" && s.onMessage != nil {
-				s.onMessage(msg)
+			reading, at, _ := s.reading.load()
+			if at.IsZero() || s.onMessage == nil {
+				continue
 			}
+			legacy, _ := s.lastMessage.Load().(string)
+			s.onMessage(Update{ScaleID: s.id, Reading: reading, At: at, Legacy: legacy})
 		}
 	}
 }
@@ -0,0 +1,80 @@
+package serial
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCapture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func waitForMessage(t *testing.T, m *SerialManager, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if got, _ := m.lastMessage.Load().(string); got == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("lastMessage = %q, want %q", m.lastMessage.Load(), want)
+}
+
+func TestLifecycleIsIdempotent(t *testing.T) {
+	m := NewSerialManager("test", Settings{PortName: ReplayScheme + writeCapture(t, "ST,GS     1.0kg\n")}, time.Hour, nil)
+
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop() before Start() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	}
+	waitForMessage(t, m, "ST,GS     1.0kg")
+	for i := 0; i < 2; i++ {
+		if err := m.Stop(); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+	}
+	if err := m.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	m.Stop()
+}
+
+func TestStartRejectsInvalidSettings(t *testing.T) {
+	m := NewSerialManager("test", Settings{PortName: "COM1"}, time.Hour, nil)
+	if err := m.Start(); err == nil {
+		m.Stop()
+		t.Fatal("Start() should reject a zero baud rate")
+	}
+}
+
+func TestReconfigureSwitchesPort(t *testing.T) {
+	first := ReplayScheme + writeCapture(t, "ST,GS     1.0kg\n")
+	second := ReplayScheme + writeCapture(t, "ST,GS     2.0kg\n")
+
+	m := NewSerialManager("test", Settings{PortName: first}, time.Hour, nil)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop()
+	waitForMessage(t, m, "ST,GS     1.0kg")
+
+	if err := m.Reconfigure(Settings{PortName: second}); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	waitForMessage(t, m, "ST,GS     2.0kg")
+	if got := m.Settings().PortName; got != second {
+		t.Fatalf("Settings().PortName = %q, want %q", got, second)
+	}
+}
@@ -0,0 +1,140 @@
+package serial
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"reader/internal/scale"
+)
+
+const (
+	rateWindow       = 10 // 计算帧率的时间窗口（秒）
+	recentErrorLimit = 10 // 保留的最近解析错误条数
+)
+
+// ParseErrorSample 是一条最近的解析错误
+type ParseErrorSample struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+	Error  string    `json:"error"`
+	Frame  string    `json:"frame"`
+}
+
+// Stats 是地磅数据源的流量和解析统计
+type Stats struct {
+	BytesIn          int64              `json:"bytesIn"`
+	FramesIn         int64              `json:"framesIn"`
+	FramesParsed     int64              `json:"framesParsed"`
+	ParseErrors      map[string]int64   `json:"parseErrors"`
+	ChecksumFailures int64              `json:"checksumFailures"` // 目前支持的型号报文不带校验，始终为 0
+	Reconnects       int                `json:"reconnects"`
+	LastGoodFrameAt  *time.Time         `json:"lastGoodFrameAt,omitempty"`
+	FrameRate        float64            `json:"frameRate"` // 最近10秒平均每秒解析成功的帧数
+	RecentErrors     []ParseErrorSample `json:"recentErrors"`
+}
+
+// rateBucket 统计某一秒内的帧数
+type rateBucket struct {
+	sec   atomic.Int64
+	count atomic.Int64
+}
+
+// frameStats 在读取循环中逐帧更新。成功路径只有原子操作，解析失败时才加锁。
+type frameStats struct {
+	bytesIn      atomic.Int64
+	framesIn     atomic.Int64
+	framesParsed atomic.Int64
+	lastGoodAt   atomic.Int64 // UnixNano
+	buckets      [rateWindow + 1]rateBucket
+
+	mu           sync.Mutex
+	parseErrors  map[string]int64
+	recentErrors []ParseErrorSample // 环形缓冲
+	nextError    int
+}
+
+func (f *frameStats) addBytes(n int) {
+	f.bytesIn.Add(int64(n))
+}
+
+func (f *frameStats) frameIn() {
+	f.framesIn.Add(1)
+}
+
+func (f *frameStats) frameParsed(now time.Time) {
+	f.framesParsed.Add(1)
+	f.lastGoodAt.Store(now.UnixNano())
+
+	// 每台地磅只有读取循环一个写入者，重置桶不需要加锁
+	sec := now.Unix()
+	b := &f.buckets[sec%int64(len(f.buckets))]
+	if b.sec.Load() != sec {
+		b.count.Store(0)
+		b.sec.Store(sec)
+	}
+	b.count.Add(1)
+}
+
+func (f *frameStats) parseError(now time.Time, frame string, err error) {
+	sample := ParseErrorSample{
+		At:     now,
+		Reason: scale.ErrorReason(err),
+		Error:  err.Error(),
+		Frame:  frame,
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.parseErrors == nil {
+		f.parseErrors = make(map[string]int64)
+	}
+	f.parseErrors[sample.Reason]++
+	if len(f.recentErrors) < recentErrorLimit {
+		f.recentErrors = append(f.recentErrors, sample)
+	} else {
+		f.recentErrors[f.nextError] = sample
+	}
+	f.nextError = (f.nextError + 1) % recentErrorLimit
+}
+
+// frameRate 返回最近 rateWindow 个完整秒内的平均帧率
+func (f *frameStats) frameRate(now time.Time) float64 {
+	current := now.Unix()
+	var total int64
+	for i := range f.buckets {
+		b := &f.buckets[i]
+		if sec := b.sec.Load(); sec < current && sec >= current-rateWindow {
+			total += b.count.Load()
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+func (f *frameStats) snapshot(now time.Time) Stats {
+	st := Stats{
+		BytesIn:      f.bytesIn.Load(),
+		FramesIn:     f.framesIn.Load(),
+		FramesParsed: f.framesParsed.Load(),
+		FrameRate:    f.frameRate(now),
+		ParseErrors:  make(map[string]int64),
+	}
+	if ns := f.lastGoodAt.Load(); ns != 0 {
+		at := time.Unix(0, ns)
+		st.LastGoodFrameAt = &at
+	}
+
+	f.mu.Lock()
+	for reason, n := range f.parseErrors {
+		st.ParseErrors[reason] = n
+	}
+	st.ChecksumFailures = f.parseErrors[scale.ReasonChecksum]
+	// 按时间从新到旧输出
+	st.RecentErrors = make([]ParseErrorSample, 0, len(f.recentErrors))
+	for i := 1; i <= len(f.recentErrors); i++ {
+		idx := (f.nextError - i + recentErrorLimit) % recentErrorLimit
+		st.RecentErrors = append(st.RecentErrors, f.recentErrors[idx])
+	}
+	f.mu.Unlock()
+	return st
+}
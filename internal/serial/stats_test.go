@@ -0,0 +1,48 @@
+package serial
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+)
+
+func TestFrameStatsSnapshot(t *testing.T) {
+	var f frameStats
+	now := time.Unix(1000, 0)
+
+	for sec := int64(0); sec < 20; sec++ {
+		at := time.Unix(980+sec, 0)
+		f.frameIn()
+		f.frameParsed(at)
+		f.frameIn()
+		f.frameParsed(at)
+	}
+	for i := 0; i < recentErrorLimit+2; i++ {
+		frame := fmt.Sprintf("bad%d", i)
+		f.frameIn()
+		_, err := scale.ParseReading(scale.ModelDefault, frame)
+		f.parseError(now, frame, err)
+	}
+	f.addBytes(128)
+
+	st := f.snapshot(now)
+	if st.FramesIn != 40+recentErrorLimit+2 || st.FramesParsed != 40 || st.BytesIn != 128 {
+		t.Fatalf("snapshot counters = %+v", st)
+	}
+	if st.FrameRate != 2 {
+		t.Fatalf("FrameRate = %v, want 2", st.FrameRate)
+	}
+	if st.ParseErrors[scale.ReasonHeader] != recentErrorLimit+2 || st.ChecksumFailures != 0 {
+		t.Fatalf("ParseErrors = %v, ChecksumFailures = %d", st.ParseErrors, st.ChecksumFailures)
+	}
+	if len(st.RecentErrors) != recentErrorLimit || st.RecentErrors[0].Frame != fmt.Sprintf("bad%d", recentErrorLimit+1) {
+		t.Fatalf("RecentErrors = %+v", st.RecentErrors)
+	}
+
+	f.parseError(now, "x", fmt.Errorf("%w: 0x1f", scale.ErrChecksum))
+	if st := f.snapshot(now); st.ChecksumFailures != 1 || st.ParseErrors[scale.ReasonChecksum] != 1 {
+		t.Fatalf("ChecksumFailures = %d, ParseErrors = %v", st.ChecksumFailures, st.ParseErrors)
+	}
+}
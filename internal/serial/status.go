@@ -0,0 +1,61 @@
+package serial
+
+import "time"
+
+// Status 是地磅数据源的运行状态
+type Status struct {
+	ID                  string        `json:"id"`
+	Port                string        `json:"port"`
+	BaudRate            int           `json:"baudRate"`
+	Model               string        `json:"model"`
+	Connected           bool          `json:"connected"`
+	LastDataAt          *time.Time    `json:"lastDataAt,omitempty"`
+	WatchdogTimeout     string        `json:"watchdogTimeout,omitempty"`
+	Reconnects          int           `json:"reconnects"`
+	LastReconnectReason string        `json:"lastReconnectReason,omitempty"`
+	LastReconnectAt     *time.Time    `json:"lastReconnectAt,omitempty"`
+	HotplugEvents       int           `json:"hotplugEvents"`
+	LastHotplug         *HotplugEvent `json:"lastHotplug,omitempty"`
+	Stats               Stats         `json:"stats"`
+}
+
+// Connected 返回端口当前是否已连接
+func (s *SerialManager) Connected() bool {
+	return s.status.isConnected()
+}
+
+// Status 返回当前运行状态快照
+func (s *SerialManager) Status() Status {
+	settings := s.Settings()
+	st := Status{
+		ID:       s.id,
+		Port:     settings.PortName,
+		BaudRate: settings.BaudRate,
+		Model:    settings.Model,
+	}
+	if settings.WatchdogTimeout > 0 {
+		st.WatchdogTimeout = settings.WatchdogTimeout.String()
+	}
+	if last := s.status.lastData(); !last.IsZero() {
+		st.LastDataAt = &last
+	}
+
+	s.status.mu.Lock()
+	st.Connected = s.status.connected
+	st.Reconnects = s.status.reconnects
+	st.LastReconnectReason = s.status.lastReason
+	if !s.status.lastReconnectAt.IsZero() {
+		at := s.status.lastReconnectAt
+		st.LastReconnectAt = &at
+	}
+	st.HotplugEvents = s.status.hotplugEvents
+	if s.status.lastHotplug != nil {
+		event := *s.status.lastHotplug
+		st.LastHotplug = &event
+	}
+	s.status.mu.Unlock()
+
+	st.Stats = s.stats.snapshot(time.Now())
+	st.Stats.Reconnects = st.Reconnects
+	return st
+}
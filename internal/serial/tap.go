@@ -0,0 +1,58 @@
+package serial
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// tap 把读取循环中的数据分发给订阅者；没有订阅者时只有一次原子读
+type tap[T any] struct {
+	count       atomic.Int32
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+	// lossless 为 true 时，缓冲区已满的订阅会被移除并关闭通道，而不是悄悄丢弃数据；
+	// 用于不能跳过任何字节的原始数据流
+	lossless bool
+}
+
+func (t *tap[T]) active() bool {
+	return t.count.Load() > 0
+}
+
+func (t *tap[T]) subscribe(buffer int) (<-chan T, func()) {
+	ch := make(chan T, buffer)
+	t.mu.Lock()
+	if t.subscribers == nil {
+		t.subscribers = make(map[chan T]struct{})
+	}
+	t.subscribers[ch] = struct{}{}
+	t.count.Store(int32(len(t.subscribers)))
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.subscribers, ch)
+			t.count.Store(int32(len(t.subscribers)))
+			t.mu.Unlock()
+		})
+	}
+}
+
+// publish 非阻塞分发，订阅者处理不过来时丢弃；lossless 时关闭该订阅
+func (t *tap[T]) publish(v T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.subscribers {
+		select {
+		case ch <- v:
+		default:
+			if t.lossless {
+				delete(t.subscribers, ch)
+				close(ch)
+				t.count.Store(int32(len(t.subscribers)))
+			}
+		}
+	}
+}
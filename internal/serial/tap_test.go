@@ -0,0 +1,29 @@
+package serial
+
+import "testing"
+
+func TestLosslessTapClosesOnOverflow(t *testing.T) {
+	var lossy, lossless tap[int]
+	lossless.lossless = true
+	dropped, cancelDropped := lossy.subscribe(1)
+	defer cancelDropped()
+	closed, cancelClosed := lossless.subscribe(1)
+	defer cancelClosed()
+
+	for i := 0; i < 2; i++ {
+		lossy.publish(i)
+		lossless.publish(i)
+	}
+
+	// 普通订阅丢弃放不下的数据，仍然有效
+	if v := <-dropped; v != 0 || !lossy.active() {
+		t.Fatalf("lossy tap: got %d, active %v", v, lossy.active())
+	}
+	// lossless 订阅收到缓冲的数据后通道关闭
+	if v, ok := <-closed; v != 0 || !ok {
+		t.Fatalf("lossless tap: first value %d, %v", v, ok)
+	}
+	if _, ok := <-closed; ok || lossless.active() {
+		t.Fatal("lossless tap still open after overflow")
+	}
+}
@@ -0,0 +1,133 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errWatchdog 表示端口在超时时间内没有收到任何数据
+var errWatchdog = errors.New("无数据看门狗触发")
+
+// activityReader 在每次读到数据时回调，用于看门狗计时、流量统计和原始数据分发
+type activityReader struct {
+	r      io.Reader
+	onRead func(p []byte)
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.onRead(p[:n])
+	}
+	return n, err
+}
+
+// connStatus 记录端口连接状态和重连情况
+type connStatus struct {
+	lastDataAt   atomic.Int64 // UnixNano，每次读到数据都会更新
+	silentStreak atomic.Int32 // 自上次收到数据以来看门狗连续触发次数
+
+	mu              sync.Mutex
+	connected       bool
+	reconnects      int
+	lastReason      string
+	lastReconnectAt time.Time
+	hotplugEvents   int
+	lastHotplug     *HotplugEvent
+}
+
+func (c *connStatus) touch() {
+	c.lastDataAt.Store(time.Now().UnixNano())
+	c.silentStreak.Store(0)
+}
+
+func (c *connStatus) lastData() time.Time {
+	if ns := c.lastDataAt.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+func (c *connStatus) setConnected(connected bool) {
+	c.mu.Lock()
+	c.connected = connected
+	c.mu.Unlock()
+}
+
+func (c *connStatus) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *connStatus) recordReconnect(reason string, watchdog bool) {
+	if watchdog {
+		c.silentStreak.Add(1)
+	}
+	c.mu.Lock()
+	c.reconnects++
+	c.lastReason = reason
+	c.lastReconnectAt = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *connStatus) recordHotplug(event HotplugEvent) {
+	c.mu.Lock()
+	c.hotplugEvents++
+	c.lastHotplug = &event
+	c.mu.Unlock()
+}
+
+// startWatchdog 在端口超过 WatchdogTimeout 没有数据时关闭端口，使读取循环重新打开它。
+// 返回的 fired 通道在看门狗触发时关闭；未启用看门狗时为nil。
+func (s *SerialManager) startWatchdog(port io.Closer, settings Settings) (stop func(), fired <-chan struct{}) {
+	timeout := settings.WatchdogTimeout
+	if timeout <= 0 {
+		return func() {}, nil
+	}
+
+	done := make(chan struct{})
+	firedCh := make(chan struct{})
+	openedAt := time.Now()
+
+	checkInterval := timeout / 4
+	if checkInterval < 100*time.Millisecond {
+		checkInterval = 100 * time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				last := s.status.lastData()
+				if last.Before(openedAt) {
+					last = openedAt
+				}
+				if idle := time.Since(last); idle > timeout {
+					logrus.WithFields(logrus.Fields{
+						"module":  "Serial",
+						"scale":   s.id,
+						"port":    settings.PortName,
+						"idle":    idle.Round(time.Millisecond),
+						"timeout": timeout,
+					}).Warn("端口长时间无数据，关闭并重新打开")
+					close(firedCh)
+					port.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, firedCh
+}
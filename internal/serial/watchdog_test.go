@@ -0,0 +1,40 @@
+package serial
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+func TestWatchdogReopensSilentPort(t *testing.T) {
+	// 打开成功但始终没有数据的串口
+	origOpen := openSerial
+	defer func() { openSerial = origOpen }()
+	openSerial = func(string, *serial.Mode) (io.ReadWriteCloser, error) { return newPipePort(), nil }
+
+	m := NewSerialManager("test", Settings{
+		PortName:        "COM3",
+		BaudRate:        9600,
+		WatchdogTimeout: 200 * time.Millisecond,
+	}, time.Hour, nil)
+	m.retryInterval = 10 * time.Millisecond
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := m.Status(); st.Reconnects > 0 {
+			if !strings.Contains(st.LastReconnectReason, "未收到数据") {
+				t.Fatalf("LastReconnectReason = %q", st.LastReconnectReason)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("watchdog did not reopen the silent port")
+}
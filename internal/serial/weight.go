@@ -0,0 +1,151 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWeightWait = 10 * time.Second // ?stable=true 未指定 timeout 时的等待时间
+	maxWeightWait     = time.Minute
+)
+
+// WeightSnapshot 是某台地磅当前的读数
+type WeightSnapshot struct {
+	ScaleID    string     `json:"scaleId"`
+	Connected  bool       `json:"connected"`
+	Weight     *float64   `json:"weight,omitempty"`
+	Unit       string     `json:"unit,omitempty"`
+	Stable     bool       `json:"stable"`
+	Mode       string     `json:"mode,omitempty"`
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+	AgeMs      *int64     `json:"age,omitempty"` // 读数距今的毫秒数
+}
+
+func newWeightSnapshot(id string, connected bool, reading scale.Reading, receivedAt time.Time) WeightSnapshot {
+	ws := WeightSnapshot{ScaleID: id, Connected: connected}
+	if receivedAt.IsZero() {
+		return ws
+	}
+	weight := reading.Value()
+	age := time.Since(receivedAt).Milliseconds()
+	ws.Weight = &weight
+	ws.Unit = reading.Unit
+	ws.Stable = reading.Stable
+	ws.Mode = reading.Mode
+	ws.ReceivedAt = &receivedAt
+	ws.AgeMs = &age
+	return ws
+}
+
+// Weight 返回当前读数
+func (s *SerialManager) Weight() WeightSnapshot {
+	reading, receivedAt, _ := s.reading.load()
+	return newWeightSnapshot(s.id, s.status.isConnected(), reading, receivedAt)
+}
+
+// WaitStable 等待调用之后收到的稳定读数，之前的稳定读数可能已经过时。
+// 超时时返回最新读数，Stable 为false。
+func (s *SerialManager) WaitStable(ctx context.Context) (WeightSnapshot, error) {
+	reading, receivedAt, err := s.reading.waitFor(ctx, time.Now(), func(r scale.Reading) bool { return r.Stable })
+	if err != nil {
+		reading.Stable = false
+	}
+	return newWeightSnapshot(s.id, s.status.isConnected(), reading, receivedAt), err
+}
+
+// ScaleSummary 是 GET /scales 中的一台地磅
+type ScaleSummary struct {
+	ID        string         `json:"id"`
+	Port      string         `json:"port"`
+	Model     string         `json:"model"`
+	Connected bool           `json:"connected"`
+	Weight    WeightSnapshot `json:"weight"`
+}
+
+// ListHandler 处理 GET /scales，返回所有地磅及其当前读数
+func (reg *Registry) ListHandler(w http.ResponseWriter, r *http.Request) {
+	managers := reg.All()
+	list := make([]ScaleSummary, 0, len(managers))
+	for _, m := range managers {
+		settings := m.Settings()
+		weight := m.Weight()
+		list = append(list, ScaleSummary{
+			ID:        m.ID(),
+			Port:      settings.PortName,
+			Model:     settings.Model,
+			Connected: weight.Connected,
+			Weight:    weight,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// parseWait 解析 timeout 参数，支持 10s、500ms 或秒数
+func parseWait(v string) (time.Duration, error) {
+	if v == "" {
+		return defaultWeightWait, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		secs, errSecs := strconv.ParseFloat(v, 64)
+		if errSecs != nil {
+			return 0, fmt.Errorf("timeout 格式无效: %s", v)
+		}
+		d = time.Duration(secs * float64(time.Second))
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout 必须大于0: %s", v)
+	}
+	if d > maxWeightWait {
+		d = maxWeightWait
+	}
+	return d, nil
+}
+
+// WeightHandler 处理 GET /scales/{id}/weight；?stable=true 时等待稳定读数，最长 timeout（默认10秒）
+func (reg *Registry) WeightHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := reg.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, ErrScaleNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if stable, _ := strconv.ParseBool(query.Get("stable")); !stable {
+		writeJSON(w, http.StatusOK, m.Weight())
+		return
+	}
+
+	wait, err := parseWait(query.Get("timeout"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	weight, err := m.WaitStable(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		// 超时时返回最新读数，stable 为false，方便调用方提示“重量不稳定”
+		writeJSON(w, http.StatusOK, weight)
+	case err != nil:
+		logrus.WithFields(logrus.Fields{
+			"module": "Serial",
+			"scale":  m.ID(),
+			"error":  err,
+		}).Debug("等待稳定读数时客户端断开")
+	default:
+		writeJSON(w, http.StatusOK, weight)
+	}
+}
@@ -0,0 +1,64 @@
+package serial
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWeightHandlerWaitsForStable(t *testing.T) {
+	reg := NewRegistry(time.Second)
+	m := NewSerialManager("a", Settings{PortName: "COM1"}, time.Hour, nil)
+	reg.Add(m)
+	m.status.setConnected(true)
+	m.reading.store(scale.Reading{Weight: "+12.0", Unit: "kg", Mode: scale.ModeGross})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/scales/{id}/weight", reg.WeightHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	get := func(query string) (int, WeightSnapshot) {
+		resp, err := http.Get(srv.URL + "/scales/a/weight" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var ws WeightSnapshot
+		json.NewDecoder(resp.Body).Decode(&ws)
+		return resp.StatusCode, ws
+	}
+
+	if code, ws := get(""); code != http.StatusOK || ws.Weight == nil || *ws.Weight != 12 || ws.Stable {
+		t.Fatalf("GET weight = %d %+v", code, ws)
+	}
+	if code, ws := get("?stable=true&timeout=50ms"); code != http.StatusOK || ws.Stable {
+		t.Fatalf("GET weight while unstable = %d %+v", code, ws)
+	}
+
+	// 请求之前的稳定读数可能已经过时，不能直接返回
+	m.reading.store(scale.Reading{Weight: "+12.0", Unit: "kg", Stable: true, Mode: scale.ModeGross})
+	if code, ws := get("?stable=true&timeout=50ms"); code != http.StatusOK || ws.Stable || ws.Weight == nil || *ws.Weight != 12 {
+		t.Fatalf("GET weight with earlier stable reading = %d %+v", code, ws)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.reading.store(scale.Reading{Weight: "+12.5", Unit: "kg", Stable: true, Mode: scale.ModeGross})
+	}()
+	if code, ws := get("?stable=true&timeout=5s"); code != http.StatusOK || !ws.Stable || *ws.Weight != 12.5 {
+		t.Fatalf("GET stable weight = %d %+v", code, ws)
+	}
+
+	resp, _ := http.Get(srv.URL + "/scales/a/weight?stable=true&timeout=abc")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid timeout status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,227 @@
+package share
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Telnet 和 RFC 2217 协议常量
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+
+	cpSignature        = 0
+	cpSetBaudRate      = 1
+	cpSetDataSize      = 2
+	cpSetParity        = 3
+	cpSetStopSize      = 4
+	cpSetControl       = 5
+	cpSetLineStateMask = 10
+	cpSetModemMask     = 11
+	cpPurgeData        = 12
+	cpServerOffset     = 100 // 服务端应答的指令码 = 客户端指令码 + 100
+)
+
+const signature = "weighbridge-reader"
+
+// maxSubnegotiation 是子协商内容的最大长度，超过时丢弃该子协商，避免不发送 IAC SE 的客户端耗尽内存
+const maxSubnegotiation = 256
+
+func supportedOption(opt byte) bool {
+	return opt == optBinary || opt == optSGA || opt == optComPort
+}
+
+// escapeIAC 对数据中的 0xFF 做转义
+func escapeIAC(data []byte) []byte {
+	if bytes.IndexByte(data, telnetIAC) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data)+8)
+	for _, b := range data {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	return out
+}
+
+type telnetState int
+
+const (
+	stateData telnetState = iota
+	stateIAC
+	stateOption
+	stateSB
+	stateSBIAC
+)
+
+// telnetSession 解析客户端发来的 Telnet 协商和 RFC 2217 串口参数指令。
+// 共享端口是只读的：设置串口参数的请求会收到当前实际参数作为应答，串口本身不做修改。
+type telnetSession struct {
+	baudRate func() int
+	reply    func([]byte)
+
+	state      telnetState
+	verb       byte
+	sb         []byte
+	sbOverflow bool          // 当前子协商超过 maxSubnegotiation，结束时丢弃
+	local      map[byte]bool // 本端已同意启用的选项
+	remote     map[byte]bool // 对端已同意启用的选项
+}
+
+func newTelnetSession(baudRate func() int, reply func([]byte)) *telnetSession {
+	return &telnetSession{
+		baudRate: baudRate,
+		reply:    reply,
+		local:    make(map[byte]bool),
+		remote:   make(map[byte]bool),
+	}
+}
+
+// start 主动声明二进制传输和抑制继续，避免客户端按 NVT 文本处理数据。
+// 返回要发送的协商指令，由客户端的写协程发送，不阻塞接受新连接。
+func (t *telnetSession) start() []byte {
+	t.local[optBinary], t.local[optSGA], t.remote[optBinary] = true, true, true
+	return []byte{
+		telnetIAC, telnetWILL, optBinary,
+		telnetIAC, telnetWILL, optSGA,
+		telnetIAC, telnetDO, optBinary,
+	}
+}
+
+// feed 处理客户端发来的字节；普通数据直接丢弃
+func (t *telnetSession) feed(data []byte) {
+	for _, b := range data {
+		switch t.state {
+		case stateData:
+			if b == telnetIAC {
+				t.state = stateIAC
+			}
+		case stateIAC:
+			switch b {
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				t.verb, t.state = b, stateOption
+			case telnetSB:
+				t.sb, t.sbOverflow, t.state = t.sb[:0], false, stateSB
+			default:
+				t.state = stateData
+			}
+		case stateOption:
+			t.negotiate(t.verb, b)
+			t.state = stateData
+		case stateSB:
+			if b == telnetIAC {
+				t.state = stateSBIAC
+			} else {
+				t.appendSB(b)
+			}
+		case stateSBIAC:
+			switch b {
+			case telnetSE:
+				if !t.sbOverflow {
+					t.subnegotiation(t.sb)
+				}
+				t.state = stateData
+			case telnetIAC:
+				t.appendSB(telnetIAC)
+				t.state = stateSB
+			default:
+				t.state = stateData
+			}
+		}
+	}
+}
+
+func (t *telnetSession) appendSB(b byte) {
+	if len(t.sb) >= maxSubnegotiation {
+		t.sbOverflow = true
+		return
+	}
+	t.sb = append(t.sb, b)
+}
+
+func (t *telnetSession) negotiate(verb, opt byte) {
+	switch verb {
+	case telnetDO:
+		if !supportedOption(opt) {
+			t.reply([]byte{telnetIAC, telnetWONT, opt})
+		} else if !t.local[opt] {
+			t.local[opt] = true
+			t.reply([]byte{telnetIAC, telnetWILL, opt})
+		}
+	case telnetWILL:
+		if !supportedOption(opt) {
+			t.reply([]byte{telnetIAC, telnetDONT, opt})
+		} else if !t.remote[opt] {
+			t.remote[opt] = true
+			t.reply([]byte{telnetIAC, telnetDO, opt})
+		}
+	case telnetDONT:
+		t.local[opt] = false
+	case telnetWONT:
+		t.remote[opt] = false
+	}
+}
+
+func (t *telnetSession) subnegotiation(sb []byte) {
+	if len(sb) < 2 || sb[0] != optComPort {
+		return
+	}
+	cmd, payload := sb[1], sb[2:]
+	var value []byte
+	switch cmd {
+	case cpSignature:
+		if len(payload) > 0 {
+			return // 客户端告知自己的签名，无需应答
+		}
+		value = []byte(signature)
+	case cpSetBaudRate:
+		value = make([]byte, 4)
+		binary.BigEndian.PutUint32(value, uint32(t.baudRate()))
+	case cpSetDataSize:
+		value = []byte{8}
+	case cpSetParity:
+		value = []byte{1} // NONE
+	case cpSetStopSize:
+		value = []byte{1}
+	case cpSetControl:
+		if len(payload) == 0 {
+			return
+		}
+		value = []byte{controlState(payload[0])}
+	case cpSetLineStateMask, cpSetModemMask, cpPurgeData:
+		value = payload
+	default:
+		return
+	}
+
+	msg := []byte{telnetIAC, telnetSB, optComPort, cmd + cpServerOffset}
+	msg = append(msg, escapeIAC(value)...)
+	msg = append(msg, telnetIAC, telnetSE)
+	t.reply(msg)
+}
+
+// controlState 返回 SET-CONTROL 请求对应的当前状态：无流控、未中断、DTR/RTS 有效
+func controlState(request byte) byte {
+	switch {
+	case request <= 3:
+		return 1
+	case request <= 6:
+		return 6
+	case request <= 9:
+		return 8
+	case request <= 12:
+		return 11
+	}
+	return request
+}
@@ -0,0 +1,374 @@
+package share
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"reader/internal/scale"
+	"reader/internal/serial"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeRaw     = "raw"
+	ModeRFC2217 = "rfc2217"
+
+	// FormatRaw 原样转发串口字节；其他取值为地磅型号，按该型号格式重新输出解析后的读数
+	FormatRaw = "raw"
+
+	clientBuffer = 256
+	writeTimeout = 5 * time.Second
+)
+
+// Config 是单台地磅的 TCP 共享配置
+type Config struct {
+	Port       int    `json:"port"`
+	Mode       string `json:"mode"`        // raw 或 rfc2217
+	Format     string `json:"format"`      // raw 或地磅型号，如 default、heb-tw
+	MaxClients int    `json:"max_clients"` // 0 表示不限制
+}
+
+// Source 是被共享的地磅数据源
+type Source interface {
+	ID() string
+	Settings() serial.Settings
+	SubscribeRaw(buffer int) (<-chan []byte, func())
+	SubscribeFrames(buffer int) (<-chan serial.FrameEvent, func())
+}
+
+// Server 把一台地磅的数据流通过 TCP 共享给多个只读客户端，供旧版称重软件使用
+type Server struct {
+	src       Source
+	cfg       Config
+	listener  net.Listener
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+type client struct {
+	conn    net.Conn
+	out     chan []byte
+	writeMu sync.Mutex
+	once    sync.Once
+}
+
+func NewServer(src Source, cfg Config) (*Server, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeRaw
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatRaw
+	}
+	if cfg.Mode != ModeRaw && cfg.Mode != ModeRFC2217 {
+		return nil, fmt.Errorf("不支持的共享模式 %q", cfg.Mode)
+	}
+	if cfg.Format != FormatRaw {
+		cfg.Format = scale.NormalizeModel(cfg.Format)
+		if _, err := scale.Format(cfg.Format, scale.Reading{Weight: "0"}); err != nil {
+			return nil, fmt.Errorf("共享输出格式无效: %w", err)
+		}
+	}
+	return &Server{
+		src:     src,
+		cfg:     cfg,
+		done:    make(chan struct{}),
+		clients: make(map[*client]struct{}),
+	}, nil
+}
+
+// Start 开始监听端口并转发数据
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("监听共享端口 %d 失败: %w", s.cfg.Port, err)
+	}
+	s.listener = listener
+
+	logrus.WithFields(logrus.Fields{
+		"module": "Share",
+		"scale":  s.src.ID(),
+		"port":   s.cfg.Port,
+		"mode":   s.cfg.Mode,
+		"format": s.cfg.Format,
+	}).Info("串口共享服务已启动")
+
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.pumpLoop()
+	return nil
+}
+
+// Addr 返回实际监听地址
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close 停止监听并断开所有客户端
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.listener.Close()
+	})
+
+	s.mu.Lock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		c.close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			logrus.WithFields(logrus.Fields{
+				"module": "Share",
+				"scale":  s.src.ID(),
+				"error":  err,
+			}).Error("接受共享连接失败")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	c := &client{conn: conn, out: make(chan []byte, clientBuffer)}
+
+	s.mu.Lock()
+	if s.closed() {
+		// Close 已经断开了全部客户端，不再登记
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	if s.cfg.MaxClients > 0 && len(s.clients) >= s.cfg.MaxClients {
+		s.mu.Unlock()
+		logrus.WithFields(logrus.Fields{
+			"module": "Share",
+			"scale":  s.src.ID(),
+			"remote": conn.RemoteAddr().String(),
+		}).Warn("共享客户端数量已达上限，拒绝连接")
+		conn.Close()
+		return
+	}
+	s.clients[c] = struct{}{}
+	count := len(s.clients)
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"module":      "Share",
+		"scale":       s.src.ID(),
+		"remote":      conn.RemoteAddr().String(),
+		"clientCount": count,
+	}).Info("共享客户端连接")
+
+	var session *telnetSession
+	var greeting []byte
+	if s.cfg.Mode == ModeRFC2217 {
+		session = newTelnetSession(func() int { return s.src.Settings().BaudRate }, func(b []byte) {
+			if err := c.write(b); err != nil {
+				s.remove(c)
+			}
+		})
+		greeting = session.start()
+	}
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.readLoop(c, session)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.writeLoop(c, greeting, session != nil)
+	}()
+}
+
+// readLoop 客户端只读：丢弃普通数据，RFC 2217 模式下处理协商指令
+func (s *Server) readLoop(c *client, session *telnetSession) {
+	defer s.remove(c)
+	buf := make([]byte, 512)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		if session != nil {
+			session.feed(buf[:n])
+		}
+	}
+}
+
+// writeLoop 先发送 Telnet 协商指令，再转发数据
+func (s *Server) writeLoop(c *client, greeting []byte, telnet bool) {
+	defer s.remove(c)
+	if len(greeting) > 0 {
+		if err := c.write(greeting); err != nil {
+			return
+		}
+	}
+	for data := range c.out {
+		if telnet {
+			data = escapeIAC(data)
+		}
+		if err := c.write(data); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	_, ok := s.clients[c]
+	delete(s.clients, c)
+	count := len(s.clients)
+	s.mu.Unlock()
+	c.close()
+
+	if ok {
+		logrus.WithFields(logrus.Fields{
+			"module":         "Share",
+			"scale":          s.src.ID(),
+			"remote":         c.conn.RemoteAddr().String(),
+			"remainingCount": count,
+		}).Info("共享客户端断开")
+	}
+}
+
+// pumpLoop 订阅地磅数据并分发给所有客户端
+func (s *Server) pumpLoop() {
+	defer s.wg.Done()
+
+	if s.cfg.Format == FormatRaw {
+		for s.pumpRaw() {
+		}
+		return
+	}
+
+	frames, cancel := s.src.SubscribeFrames(clientBuffer)
+	defer cancel()
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-frames:
+			if event.Reading == nil {
+				continue
+			}
+			frame, err := scale.Format(s.cfg.Format, *event.Reading)
+			if err != nil {
+				continue
+			}
+			if !s.broadcast([]byte(frame)) {
+				return
+			}
+		}
+	}
+}
+
+// pumpRaw 转发一次原始字节订阅。订阅因缓冲区溢出被关闭时字节流已经缺失，
+// 断开全部客户端并返回 true 重新订阅；服务关闭时返回 false。
+func (s *Server) pumpRaw() bool {
+	raw, cancel := s.src.SubscribeRaw(clientBuffer)
+	defer cancel()
+	for {
+		select {
+		case <-s.done:
+			return false
+		case data, ok := <-raw:
+			if !ok {
+				s.disconnectAll("共享转发过慢，原始数据缺失，断开全部客户端")
+				return !s.closed()
+			}
+			if !s.broadcast(data) {
+				return false
+			}
+		}
+	}
+}
+
+// disconnectAll 断开当前全部客户端，服务继续接受新连接
+func (s *Server) disconnectAll(reason string) {
+	s.mu.Lock()
+	count := len(s.clients)
+	for c := range s.clients {
+		delete(s.clients, c)
+		c.close()
+	}
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"module":      "Share",
+		"scale":       s.src.ID(),
+		"clientCount": count,
+	}).Warn(reason)
+}
+
+// closed 判断 Close 是否已被调用
+func (s *Server) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// broadcast 原始字节流不能跳帧，缓冲区满的客户端直接断开。
+// 服务已关闭时返回 false：select 在 done 和数据同时就绪时随机选择，pumpLoop 可能在关闭后仍然取到数据。
+func (s *Server) broadcast(data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed() {
+		return false
+	}
+	for c := range s.clients {
+		select {
+		case c.out <- data:
+		default:
+			logrus.WithFields(logrus.Fields{
+				"module": "Share",
+				"scale":  s.src.ID(),
+				"remote": c.conn.RemoteAddr().String(),
+			}).Warn("共享客户端接收过慢，断开连接")
+			delete(s.clients, c)
+			c.close()
+		}
+	}
+	return true
+}
+
+func (c *client) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.out)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,239 @@
+package share
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"reader/internal/scale"
+	"reader/internal/serial"
+)
+
+type fakeSource struct {
+	mu     sync.Mutex
+	raw    chan []byte
+	frames chan serial.FrameEvent
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{raw: make(chan []byte, 10), frames: make(chan serial.FrameEvent, 10)}
+}
+
+func (f *fakeSource) ID() string                { return "test" }
+func (f *fakeSource) Settings() serial.Settings { return serial.Settings{BaudRate: 9600} }
+func (f *fakeSource) SubscribeRaw(int) (<-chan []byte, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.raw, func() {}
+}
+
+// overflow 模拟原始数据订阅溢出：关闭当前订阅，之后的订阅拿到新的通道
+func (f *fakeSource) overflow() {
+	f.mu.Lock()
+	old := f.raw
+	f.raw = make(chan []byte, 10)
+	f.mu.Unlock()
+	close(old)
+}
+func (f *fakeSource) SubscribeFrames(int) (<-chan serial.FrameEvent, func()) {
+	return f.frames, func() {}
+}
+
+func startServer(t *testing.T, src Source, cfg Config) net.Conn {
+	t.Helper()
+	srv, err := NewServer(src, cfg)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	return conn
+}
+
+// waitClient 等待服务端登记新连接，避免数据在客户端登记前发出
+func waitClient() {
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestRawShare(t *testing.T) {
+	src := newFakeSource()
+	conn := startServer(t, src, Config{})
+	waitClient()
+
+	src.raw <- []byte("ST,GS     0.0kg\r\n")
+	got := make([]byte, 17)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ST,GS     0.0kg\r\n" {
+		t.Fatalf("received %q", got)
+	}
+}
+
+func TestFormattedShare(t *testing.T) {
+	src := newFakeSource()
+	conn := startServer(t, src, Config{Format: "heb-tw"})
+	waitClient()
+
+	src.frames <- serial.FrameEvent{Error: "无法解析"}
+	src.frames <- serial.FrameEvent{Reading: &scale.Reading{Weight: "59.6", Unit: "kg", Stable: true, Mode: scale.ModeGross}}
+	got := make([]byte, 13)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "wn00059.6kg\r\n" {
+		t.Fatalf("received %q", got)
+	}
+}
+
+func TestRFC2217Share(t *testing.T) {
+	src := newFakeSource()
+	conn := startServer(t, src, Config{Mode: ModeRFC2217})
+
+	greeting := make([]byte, 9)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		t.Fatal(err)
+	}
+
+	// 客户端启用 COM-PORT-OPTION 并请求把波特率改为 115200
+	conn.Write([]byte{telnetIAC, telnetWILL, optComPort})
+	conn.Write([]byte{telnetIAC, telnetSB, optComPort, cpSetBaudRate, 0, 1, 0xc2, 0, telnetIAC, telnetSE})
+
+	want := []byte{
+		telnetIAC, telnetDO, optComPort,
+		telnetIAC, telnetSB, optComPort, cpSetBaudRate + cpServerOffset, 0, 0, 0x25, 0x80, telnetIAC, telnetSE,
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("negotiation reply = %v, want %v", got, want)
+	}
+
+	src.raw <- []byte{0x02, telnetIAC, 0x03}
+	data := make([]byte, 4)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x02, telnetIAC, telnetIAC, 0x03}) {
+		t.Fatalf("data = %v, want IAC escaped", data)
+	}
+}
+
+func TestRawOverflowDisconnectsClients(t *testing.T) {
+	src := newFakeSource()
+	srv, err := NewServer(src, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitClient()
+
+	// 字节流缺失后客户端被断开，不会收到拼接错乱的数据
+	src.overflow()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read after overflow: err = %v, want EOF", err)
+	}
+
+	// 重新订阅后新连接照常收到数据
+	conn2, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	waitClient()
+	src.mu.Lock()
+	src.raw <- []byte("ST\r\n")
+	src.mu.Unlock()
+	conn2.SetReadDeadline(time.Now().Add(3 * time.Second))
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn2, got); err != nil || string(got) != "ST\r\n" {
+		t.Fatalf("data after resubscribe = %q, %v", got, err)
+	}
+}
+
+func TestCloseWhileBroadcasting(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		src := newFakeSource()
+		srv, err := NewServer(src, Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+		var conns []net.Conn
+		for j := 0; j < 10; j++ {
+			conn, err := net.Dial("tcp", srv.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns = append(conns, conn)
+		}
+		waitClient()
+
+		// 关闭期间数据源仍在产生数据，不能向已关闭的客户端通道发送
+		stop := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case src.raw <- []byte("ST,GS,+001.000kg\r\n"):
+				case <-stop:
+					return
+				}
+			}
+		}()
+		srv.Close()
+		close(stop)
+		if len(srv.clients) != 0 || srv.broadcast([]byte("x")) {
+			t.Fatalf("after Close: %d clients still registered, broadcast accepted", len(srv.clients))
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+func TestSubnegotiationLimit(t *testing.T) {
+	var replies [][]byte
+	session := newTelnetSession(func() int { return 9600 }, func(b []byte) { replies = append(replies, b) })
+
+	session.feed([]byte{telnetIAC, telnetSB, optComPort, cpSignature})
+	session.feed(bytes.Repeat([]byte{'x'}, 10*maxSubnegotiation))
+	if len(session.sb) > maxSubnegotiation {
+		t.Fatalf("subnegotiation buffer grew to %d bytes", len(session.sb))
+	}
+	session.feed([]byte{telnetIAC, telnetSE})
+	if len(replies) != 0 {
+		t.Fatalf("oversized subnegotiation answered: %v", replies)
+	}
+
+	// 之后的正常子协商不受影响
+	session.feed([]byte{telnetIAC, telnetSB, optComPort, cpSignature, telnetIAC, telnetSE})
+	if len(replies) != 1 || !bytes.Contains(replies[0], []byte(signature)) {
+		t.Fatalf("signature reply = %v", replies)
+	}
+}
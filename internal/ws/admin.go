@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"reader/internal/auth"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// ClientInfo 是管理接口列出的单个连接，比 /ws/stats 多了远程地址、订阅主题、客户端身份和 User-Agent
+type ClientInfo struct {
+	ClientStats
+	Remote    string   `json:"remote"`
+	Topics    []string `json:"topics"`
+	UserAgent string   `json:"userAgent"`
+	Identity  string   `json:"identity"` // 令牌名称，未携带令牌时为 anonymous
+}
+
+// Clients 返回当前全部 WebSocket 和 SSE 连接，按 ID 排序
+func (h *Hub) Clients() []ClientInfo {
+	now := time.Now()
+	clients := h.clients.load()
+	list := make([]ClientInfo, 0, len(clients))
+	for _, c := range clients {
+		list = append(list, ClientInfo{
+			ClientStats: c.stats(now),
+			Remote:      c.remote,
+			Topics:      c.subs.list(),
+			UserAgent:   c.userAgent,
+			Identity:    c.identity.Name,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Disconnect 断开指定客户端，客户端不存在时返回 false。
+// WebSocket 连接收到 1008 关闭帧，SSE 连接收到 close 事件。
+func (h *Hub) Disconnect(id uint64) bool {
+	for _, c := range h.clients.load() {
+		if c.id == id {
+			c.close(websocket.ClosePolicyViolation, reasonAdmin)
+			return true
+		}
+	}
+	return false
+}
+
+// ClientsHandler 处理 GET /admin/clients
+func (h *Hub) ClientsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Clients()); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "WebSocket",
+			"error":  err,
+		}).Error("响应编码失败")
+	}
+}
+
+// DisconnectHandler 处理 DELETE /admin/clients/{id}
+func (h *Hub) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "客户端ID无效", http.StatusBadRequest)
+		return
+	}
+	if !h.Disconnect(id) {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"module":   "WebSocket",
+		"client":   id,
+		"operator": auth.IdentityFrom(r.Context()).Name,
+	}).Warn("管理员断开客户端")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func TestAdminClientsAndLimit(t *testing.T) {
+	h := NewHub(Config{MaxClients: 2})
+	r := mux.NewRouter()
+	r.HandleFunc("/ws", h.HandleWS)
+	r.HandleFunc("/events", h.HandleSSE)
+	r.HandleFunc("/admin/clients", h.ClientsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/admin/clients/{id}", h.DisconnectHandler).Methods(http.MethodDelete)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?topics=scale:a"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"User-Agent": {"kiosk/1.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	openSSE(t, srv.URL+"/events", "")
+	waitClients(t, h, 2)
+
+	// 达到上限后 WebSocket 和 SSE 都返回 503
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("dial over limit: err = %v, resp = %v", err, resp)
+	}
+	resp, err = http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), "上限") {
+		t.Fatalf("SSE over limit: status = %d, body = %q", resp.StatusCode, body)
+	}
+	if st := h.Stats(); st.Rejected != 2 || st.MaxClients != 2 {
+		t.Fatalf("Stats() rejected = %d, maxClients = %d", st.Rejected, st.MaxClients)
+	}
+
+	resp, err = http.Get(srv.URL + "/admin/clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clients []ClientInfo
+	err = json.NewDecoder(resp.Body).Decode(&clients)
+	resp.Body.Close()
+	if err != nil || len(clients) != 2 {
+		t.Fatalf("GET /admin/clients = %+v, %v", clients, err)
+	}
+	wsClient := clients[0]
+	if wsClient.Transport != TransportWS || wsClient.UserAgent != "kiosk/1.0" || wsClient.Identity != "anonymous" || wsClient.Remote == "" ||
+		len(wsClient.Topics) != 1 || wsClient.Topics[0] != "scale:a" || wsClient.ConnectedAt.IsZero() {
+		t.Fatalf("websocket client = %+v", wsClient)
+	}
+	if clients[1].Transport != TransportSSE {
+		t.Fatalf("sse client = %+v", clients[1])
+	}
+	// 公开的 /ws/stats 不暴露远程地址和订阅
+	stats, _ := json.Marshal(h.Stats())
+	if strings.Contains(string(stats), "remote") || strings.Contains(string(stats), "scale:a") {
+		t.Fatalf("Stats() exposes client details: %s", stats)
+	}
+	if !strings.Contains(string(stats), `"ssePolicy":`) {
+		t.Fatalf("Stats() = %s, want camelCase ssePolicy", stats)
+	}
+
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{{"abc", http.StatusBadRequest}, {"999", http.StatusNotFound}, {strconv.FormatUint(wsClient.ID, 10), http.StatusNoContent}} {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/admin/clients/"+tc.id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("DELETE /admin/clients/%s = %d, want %d", tc.id, resp.StatusCode, tc.want)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reasonAdmin {
+		t.Fatalf("ReadMessage() error = %v, want close %d", err, websocket.ClosePolicyViolation)
+	}
+
+	// 断开后空出的名额可以再连接
+	waitClients(t, h, 1)
+	dial(t, srv.URL+"/ws")
+	waitClients(t, h, 2)
+}
+
+func TestRefuseAfterUpgrade(t *testing.T) {
+	h := NewHub(Config{MaxClients: 1})
+	h.Publish(NewWeightMessage("a", 1, "kg", true, "GS", "", time.Now()))
+	// 升级过程中另一个连接抢先占满名额
+	h.upgrader.CheckOrigin = func(r *http.Request) bool {
+		other := &client{send: newSendQueue(Backpressure{}), closed: make(chan struct{})}
+		if _, err := h.clients.add(other, h.cfg.MaxClients); err != nil {
+			t.Error(err)
+		}
+		return true
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 只收到关闭帧，没有快照
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseTryAgainLater || closeErr.Text != reasonTooMany {
+		t.Fatalf("ReadMessage() = %q, %v, want close %d", data, err, websocket.CloseTryAgainLater)
+	}
+	if n, rejected := h.clients.len(), h.rejected.Load(); n != 1 || rejected != 1 {
+		t.Fatalf("clients = %d, rejected = %d", n, rejected)
+	}
+}
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// maxBatch 是合并到同一帧的最多消息数，连接时补发的大量事件会分成多帧
+const maxBatch = 64
+
+// requestBatch 读取 ?batch=true：客户端能够解析数组帧时，排队的多条消息合并为一帧发送
+func requestBatch(r *http.Request) bool {
+	batch, _ := strconv.ParseBool(r.URL.Query().Get("batch"))
+	return batch
+}
+
+// batchFrame 把多条已编码的消息合并为一个数组：JSON 为 [a,b]，MessagePack 和 CBOR 为数组头加各条消息
+func batchFrame(encoding string, payloads [][]byte) []byte {
+	switch encoding {
+	case EncodingMsgpack:
+		raws := make([]msgpack.RawMessage, len(payloads))
+		for i, p := range payloads {
+			raws[i] = p
+		}
+		buf, _ := marshalMsgpack(raws)
+		return buf
+	case EncodingCBOR:
+		raws := make([]cbor.RawMessage, len(payloads))
+		for i, p := range payloads {
+			raws[i] = p
+		}
+		buf, _ := marshalCBOR(raws)
+		return buf
+	}
+
+	size := len(payloads) + 2
+	for _, p := range payloads {
+		size += len(p)
+	}
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, '[')
+	for i, p := range payloads {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, p...)
+	}
+	return append(buf, ']')
+}
@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// cborMode 按键排序编码 map，同一消息每次编码结果相同；浮点数保持 float64，与 MessagePack 一致
+var cborMode = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{Sort: cbor.SortBytewiseLexical}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalCBOR(v interface{}) ([]byte, error) {
+	return cborMode.Marshal(v)
+}
+
+// plainValue 把 JSON 解码出的 json.Number 换成整数或浮点数，其余值原样返回
+func plainValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []interface{}:
+		for i, item := range v {
+			var err error
+			if v[i], err = plainValue(item); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			var err error
+			if v[k], err = plainValue(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// plainData 把 Data 转成 JSON 解码后的通用值，字段名和取舍与 JSON 信封一致
+func plainData(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return plainValue(v)
+}
+
+// binaryEnvelope 按 JSON 信封的字段名和 omitempty 规则生成通用值，由 MessagePack 或 CBOR 编码
+func (m *Message) binaryEnvelope() (map[string]interface{}, error) {
+	env := map[string]interface{}{"v": int64(m.V), "type": m.Type, "ts": m.TS}
+	if m.ScaleID != "" {
+		env["scaleId"] = m.ScaleID
+	}
+	if m.Weight != nil {
+		env["weight"] = *m.Weight
+	}
+	if m.Unit != "" {
+		env["unit"] = m.Unit
+	}
+	if m.Stable != nil {
+		env["stable"] = *m.Stable
+	}
+	if m.Status != "" {
+		env["status"] = m.Status
+	}
+	if m.Stream != "" {
+		env["stream"] = m.Stream
+	}
+	if m.Seq != 0 {
+		env["seq"] = m.Seq
+	}
+	if m.Data != nil {
+		data, err := plainData(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		env["data"] = data
+	}
+	if m.Snapshot {
+		env["snapshot"] = true
+	}
+	if m.AgeMs != 0 {
+		env["age"] = m.AgeMs
+	}
+	return env, nil
+}
+
+// encodeBinary 用 marshal 编码二进制信封
+func (m *Message) encodeBinary(marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+	env, err := m.binaryEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return marshal(env)
+}
@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+
+// decodeBinary 用 MessagePack 或 CBOR 库解码，再经 JSON 转换，数字统一为 float64，便于与 JSON 信封比较
+func decodeBinary(t *testing.T, encoding string, data []byte) interface{} {
+	t.Helper()
+	var v interface{}
+	var err error
+	if encoding == EncodingCBOR {
+		err = cborDecMode.Unmarshal(data, &v)
+	} else {
+		err = msgpack.Unmarshal(data, &v)
+	}
+	if err != nil {
+		t.Fatalf("%s decode %x: %v", encoding, data, err)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	json.Unmarshal(raw, &out)
+	return out
+}
+
+func jsonEnvelope(t *testing.T, m *Message) interface{} {
+	t.Helper()
+	raw, _ := m.encode(EncodingJSON)
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestBinaryEnvelopes(t *testing.T) {
+	snapshot := NewWeightMessage("a", 12, "kg", false, "NT", "", time.UnixMilli(1700000000000))
+	snapshot.Seq, snapshot.Snapshot, snapshot.AgeMs = 1<<40, true, 1500
+	msgs := []*Message{
+		NewWeightMessage("a", 12.5, "kg", true, "GS", "", time.UnixMilli(1700000000000)),
+		snapshot,
+		NewEventMessage(TypeStatus, "a", map[string]interface{}{"state": "connected", "port": "COM3", "n": -1000, "f": 2.5}),
+		NewEventMessage(TypePrintJob, "", []interface{}{"x", nil, true, []int{1, 2}}),
+	}
+	for _, msg := range msgs {
+		want := jsonEnvelope(t, msg)
+		for _, encoding := range []string{EncodingMsgpack, EncodingCBOR} {
+			data, ok := msg.encode(encoding)
+			if !ok {
+				t.Fatalf("%s encode %+v failed", encoding, msg)
+			}
+			if got := decodeBinary(t, encoding, data); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s envelope = %v, want %v", encoding, got, want)
+			}
+		}
+	}
+
+	// 重量始终是浮点数，整数使用最短编码
+	var env map[string]interface{}
+	data, _ := msgs[1].encode(EncodingMsgpack)
+	if err := msgpack.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env["weight"].(float64); !ok {
+		t.Errorf("msgpack weight = %T, want float64", env["weight"])
+	}
+	if _, ok := env["v"].(int8); !ok {
+		t.Errorf("msgpack v = %T, want int8", env["v"])
+	}
+}
+
+func TestHubMsgpackEncoding(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL, SubprotocolMsgpack)
+	if conn.Subprotocol() != SubprotocolMsgpack {
+		t.Fatalf("Subprotocol() = %q", conn.Subprotocol())
+	}
+	waitClients(t, h, 1)
+
+	msgs := []*Message{
+		NewWeightMessage("a", 12.5, "kg", true, "GS", "", time.UnixMilli(1700000000000)),
+		NewEventMessage(TypeStatus, "a", map[string]interface{}{"state": "connected", "port": "COM3"}),
+	}
+	for _, msg := range msgs {
+		h.Publish(msg)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		frame, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame != websocket.BinaryMessage {
+			t.Fatalf("frame type = %d, want binary", frame)
+		}
+		// 二进制信封与 JSON 信封的内容一致
+		if got, want := decodeBinary(t, EncodingMsgpack, data), jsonEnvelope(t, msg); !reflect.DeepEqual(got, want) {
+			t.Fatalf("msgpack envelope = %v, want %v", got, want)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	messages := map[string]func() *Message{
+		"weight": func() *Message {
+			return NewWeightMessage("entry", 12345.5, "kg", true, "GS", "", time.Now())
+		},
+		"status": func() *Message {
+			return NewEventMessage(TypeStatus, "entry", map[string]interface{}{"state": "connected", "port": "COM3"})
+		},
+	}
+	for name, newMsg := range messages {
+		for _, encoding := range []string{EncodingJSON, EncodingMsgpack, EncodingCBOR} {
+			b.Run(name+"/"+encoding, func(b *testing.B) {
+				b.ReportAllocs()
+				var size int
+				for i := 0; i < b.N; i++ {
+					data, _ := newMsg().encode(encoding)
+					size = len(data)
+				}
+				b.ReportMetric(float64(size), "bytes/msg")
+			})
+		}
+	}
+}
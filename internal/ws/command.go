@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"reader/internal/auth"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errForbidden 表示客户端的令牌没有发送指令的权限
+var errForbidden = errors.New("权限不足")
+
+// CommandFunc 执行客户端通过 WebSocket 发来的地磅指令
+type CommandFunc func(ctx context.Context, scaleID, command string) (interface{}, error)
+
+// clientMessage 是客户端发往服务端的消息
+type clientMessage struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"` // 客户端自定义的请求ID，原样回传
+	ScaleID string   `json:"scaleId"`
+	Command string   `json:"command"`
+	Topics  []string `json:"topics,omitempty"`
+	Rate    float64  `json:"rate,omitempty"` // 每秒最多推送几条读数，0 表示不限制
+}
+
+type subscriptionsReply struct {
+	Type   string   `json:"type"`
+	ID     string   `json:"id,omitempty"`
+	Topics []string `json:"topics"`
+}
+
+// rateReply 回复生效的推送频率，0 表示不限制
+type rateReply struct {
+	Type string  `json:"type"`
+	ID   string  `json:"id,omitempty"`
+	Rate float64 `json:"rate"`
+}
+
+type commandReply struct {
+	Type    string      `json:"type"`
+	ID      string      `json:"id,omitempty"`
+	Success bool        `json:"success"`
+	Result  interface{} `json:"result,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// SetCommandHandler 设置地磅指令的执行函数，未设置时忽略客户端指令
+func (h *Hub) SetCommandHandler(fn CommandFunc) {
+	h.lock.Lock()
+	h.onCommand = fn
+	h.lock.Unlock()
+}
+
+// handleClientMessage 处理客户端发来的控制消息：subscribe、unsubscribe、rate 和 command
+func (h *Hub) handleClientMessage(c *client, data []byte) {
+	var msg clientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	switch msg.Type {
+	case "subscribe":
+		c.subs.subscribe(msg.Topics)
+		h.replySubscriptions(c, msg.ID)
+		if len(msg.Topics) > 0 {
+			h.sendSnapshot(c, msg.Topics)
+		}
+	case "unsubscribe":
+		c.subs.unsubscribe(msg.Topics)
+		h.replySubscriptions(c, msg.ID)
+	case TypeRate:
+		reply := rateReply{Type: TypeRate, ID: msg.ID, Rate: h.setRate(c, msg.Rate)}
+		data, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+		out := NewEventMessage(TypeRate, "", reply)
+		out.legacy = string(data)
+		h.sendTo(c, out)
+	case "command":
+		h.handleCommand(c, msg)
+	}
+}
+
+// replySubscriptions 回复客户端当前订阅的主题
+func (h *Hub) replySubscriptions(c *client, id string) {
+	reply := subscriptionsReply{Type: TypeSubscriptions, ID: id, Topics: c.subs.list()}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	out := NewEventMessage(TypeSubscriptions, "", reply)
+	out.legacy = string(data)
+	h.sendTo(c, out)
+}
+
+func (h *Hub) handleCommand(c *client, msg clientMessage) {
+	h.lock.RLock()
+	onCommand := h.onCommand
+	h.lock.RUnlock()
+	if onCommand == nil {
+		return
+	}
+
+	// 指令确认需要等待后续读数，放到独立goroutine避免阻塞读循环
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		var result interface{}
+		err := errForbidden
+		if c.identity.Has(auth.ScopeAdmin) {
+			result, err = onCommand(ctx, msg.ScaleID, msg.Command)
+		}
+		reply := commandReply{Type: TypeCommandResult, ID: msg.ID, Success: err == nil, Result: result}
+		if err != nil {
+			reply.Message = err.Error()
+		}
+		data, err := json.Marshal(reply)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "WebSocket",
+				"error":  err,
+			}).Error("指令结果编码失败")
+			return
+		}
+		// 旧版客户端收到原来的回复格式，JSON 客户端收到信封，data 为同样的回复
+		out := NewEventMessage(TypeCommandResult, msg.ScaleID, reply)
+		out.legacy = string(data)
+		h.sendTo(c, out)
+	}()
+}
+
+// sendTo 向单个客户端发送消息，客户端已断开时丢弃，队列满时按策略处理
+func (h *Hub) sendTo(c *client, msg *Message) {
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+	if !c.send.push(msg) {
+		h.dropSlow(c)
+	}
+}
@@ -0,0 +1,237 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// attach 像新连接一样登记客户端并取得快照（不发送），由 drain 在后台取走发送队列里的消息，返回断开函数
+func attach(h *Hub, drain func([]*Message)) (*client, func()) {
+	c := &client{
+		id:          h.nextID.Add(1),
+		transport:   TransportWS,
+		encoding:    EncodingJSON,
+		subs:        newSubscriptions(nil),
+		send:        newSendQueue(h.cfg.Backpressure),
+		throttle:    newThrottle(),
+		connectedAt: time.Now(),
+		closed:      make(chan struct{}),
+	}
+	h.clients.add(c, 0)
+	h.backlog(c, 0, false)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-c.send.notify:
+				drain(c.send.pop())
+			}
+		}
+	}()
+	return c, func() {
+		h.clients.remove(c)
+		c.close(0, "")
+		<-done
+	}
+}
+
+func TestHubConcurrentChurn(t *testing.T) {
+	h := NewHub(Config{Backpressure: Backpressure{Policy: PolicyDropOldest, BufferSize: 64}})
+	const publishers, churners, rounds = 4, 8, 50
+
+	var wg sync.WaitGroup
+	var outOfOrder atomic.Int64
+	stop := make(chan struct{})
+
+	// 每个客户端收到的事件序号必须递增
+	for i := 0; i < churners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				var last uint64
+				_, detach := attach(h, func(msgs []*Message) {
+					for _, m := range msgs {
+						if m.Stream != StreamEvents {
+							continue
+						}
+						if m.Seq <= last {
+							outOfOrder.Add(1)
+						}
+						last = m.Seq
+					}
+				})
+				time.Sleep(time.Millisecond)
+				detach()
+			}
+		}()
+	}
+
+	var pub sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		pub.Add(1)
+		go func(i int) {
+			defer pub.Done()
+			scaleID := fmt.Sprintf("s%d", i)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				h.Publish(NewWeightMessage(scaleID, 1, "kg", true, "GS", "", time.Now()))
+				h.Publish(NewEventMessage(TypeStatus, scaleID, nil))
+				h.Stats()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(stop)
+	pub.Wait()
+
+	if n := outOfOrder.Load(); n != 0 {
+		t.Fatalf("%d events delivered out of order", n)
+	}
+	if n := h.GetClientCount(); n != 0 {
+		t.Fatalf("GetClientCount() = %d after all clients left", n)
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		for _, churn := range []bool{false, true} {
+			name := fmt.Sprintf("clients=%d", n)
+			if churn {
+				name += "/churn"
+			}
+			b.Run(name, func(b *testing.B) {
+				h := NewHub(Config{Backpressure: Backpressure{Policy: PolicyConflate}})
+				var detachAll []func()
+				for i := 0; i < n; i++ {
+					_, detach := attach(h, func([]*Message) {})
+					detachAll = append(detachAll, detach)
+				}
+				defer func() {
+					for _, detach := range detachAll {
+						detach()
+					}
+				}()
+
+				// 同时不断有客户端连接和断开，每次间隔 100 微秒
+				stop := make(chan struct{})
+				var wg sync.WaitGroup
+				if churn {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for {
+							select {
+							case <-stop:
+								return
+							default:
+							}
+							_, detach := attach(h, func([]*Message) {})
+							detach()
+							time.Sleep(100 * time.Microsecond)
+						}
+					}()
+				}
+
+				// 消息发布后不可修改，每次发布新的消息
+				now := time.Now()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					h.Publish(NewWeightMessage("entry", float64(i), "kg", true, "GS", "", now))
+				}
+				b.StopTimer()
+				close(stop)
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func TestHubKeepsEventsUnderReadingBurst(t *testing.T) {
+	for _, policy := range []string{PolicyConflate, PolicyDropOldest} {
+		t.Run(policy, func(t *testing.T) {
+			h := NewHub(Config{Backpressure: Backpressure{Policy: policy, BufferSize: 4}})
+			// 不取走发送队列，相当于发送goroutine卡在慢网络上
+			c := &client{
+				id:       h.nextID.Add(1),
+				encoding: EncodingJSON,
+				subs:     newSubscriptions(nil),
+				send:     newSendQueue(h.cfg.Backpressure),
+				throttle: newThrottle(),
+				closed:   make(chan struct{}),
+			}
+			h.clients.add(c, 0)
+
+			h.Publish(NewEventMessage(TypeStatus, "a", map[string]string{"state": "connected"}))
+			for i := 0; i < 50; i++ {
+				h.Publish(NewWeightMessage(fmt.Sprintf("s%d", i%8), float64(i), "kg", false, "GS", "", time.Now()))
+			}
+
+			var events int
+			for _, m := range c.send.pop() {
+				if m.Type == TypeStatus && m.Seq == 1 {
+					events++
+				}
+			}
+			if events != 1 {
+				t.Fatal("status event was evicted by readings")
+			}
+			if h.GetClientCount() != 1 {
+				t.Fatal("client disconnected while readings could be dropped")
+			}
+		})
+	}
+}
+
+func TestHubStateIsImmutable(t *testing.T) {
+	h := NewHub(Config{ReplaySize: 2})
+	h.Publish(NewEventMessage(TypeStatus, "a", "connected"))
+	before := h.state.Load()
+	for i := 0; i < 3; i++ {
+		h.Publish(NewEventMessage(TypeStatus, "a", "disconnected"))
+	}
+	after := h.state.Load()
+
+	if before.seqs[StreamEvents] != 1 || len(before.replay.msgs) != 1 || before.replay.msgs[0].Seq != 1 {
+		t.Fatalf("earlier state changed: seqs %v, replay %d", before.seqs, len(before.replay.msgs))
+	}
+	if before.retained["status/scale:a"].Data != "connected" {
+		t.Fatal("earlier state's retained status changed")
+	}
+	if after.seqs[StreamEvents] != 4 || after.replay.oldest() != 3 {
+		t.Fatalf("latest state: seq %d, oldest %d", after.seqs[StreamEvents], after.replay.oldest())
+	}
+}
+
+func TestClientPendingSkipsBacklog(t *testing.T) {
+	c := &client{send: newSendQueue(Backpressure{BufferSize: 10}), after: 5}
+	published := func(order uint64) *Message {
+		m := NewEventMessage(TypeStatus, "a", nil)
+		m.order = order
+		return m
+	}
+	snapshot := published(3).snapshot(time.Now())
+	reply := NewEventMessage(TypeCommandResult, "a", nil)
+	for _, m := range []*Message{published(4), published(5), snapshot, reply, published(6)} {
+		c.send.push(m)
+	}
+	got := c.pending()
+	if len(got) != 3 || got[0] != snapshot || got[1] != reply || got[2].order != 6 {
+		t.Fatalf("pending() = %v", got)
+	}
+	if c.after != 0 {
+		t.Fatalf("after = %d, want 0 once newer messages arrive", c.after)
+	}
+}
@@ -0,0 +1,181 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProtocolVersion 是 JSON 消息信封的版本号
+const ProtocolVersion = 1
+
+// 消息类型
+const (
+	TypeWeight        = "weight"
+	TypeStatus        = "status"
+	TypePrintJob      = "print_job"
+	TypeCommandResult = "command_result"
+	TypeSubscriptions = "subscriptions"
+	TypeResync        = "resync" // 无法续传，随后发送最新状态快照
+	TypeRate          = "rate"
+)
+
+// 消息流，每个流的序号独立递增
+const (
+	StreamWeights = "weights" // 读数，可以丢失，断线后只补发最新值
+	StreamEvents  = "events"  // 状态和打印任务，断线后按序号补发
+)
+
+// messageStream 返回消息类型所属的流，指令结果等单发消息不属于任何流
+func messageStream(msgType string) string {
+	switch msgType {
+	case TypeWeight:
+		return StreamWeights
+	case TypeStatus, TypePrintJob:
+		return StreamEvents
+	}
+	return ""
+}
+
+// 客户端可选的消息编码
+const (
+	EncodingText    = "text" // 旧版文本，如 ST,GS     12.3kg
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack" // 与 JSON 相同的信封，以二进制帧发送
+	EncodingCBOR    = "cbor"
+)
+
+// 选择消息编码的 WebSocket 子协议名
+const (
+	SubprotocolJSON    = "weighbridge.v1.json"
+	SubprotocolMsgpack = "weighbridge.v1.msgpack"
+	SubprotocolCBOR    = "weighbridge.v1.cbor"
+)
+
+// binaryEncoding 判断编码是否以二进制帧发送
+func binaryEncoding(encoding string) bool {
+	return encoding == EncodingMsgpack || encoding == EncodingCBOR
+}
+
+// Message 是推送给客户端的消息。发布后在多个客户端间共享，不可再修改。
+type Message struct {
+	V       int         `json:"v"`
+	Type    string      `json:"type"`
+	ScaleID string      `json:"scaleId,omitempty"`
+	Weight  *float64    `json:"weight,omitempty"`
+	Unit    string      `json:"unit,omitempty"`
+	Stable  *bool       `json:"stable,omitempty"`
+	Status  string      `json:"status,omitempty"` // 重量消息为 GS（毛重）或 NT（净重）
+	TS      int64       `json:"ts"`               // Unix 毫秒
+	Stream  string      `json:"stream,omitempty"`
+	Seq     uint64      `json:"seq,omitempty"` // 在所属流内单调递增
+	Data    interface{} `json:"data,omitempty"`
+
+	// 连接或订阅时补发的最新状态，AgeMs 为该状态距今的毫秒数
+	Snapshot bool  `json:"snapshot,omitempty"`
+	AgeMs    int64 `json:"age,omitempty"`
+
+	// order 是发布顺序，用于快照排序
+	order uint64
+	// topic 决定哪些订阅者收到该消息，为空时发给所有客户端
+	topic string
+	// legacy 是发给旧版文本客户端的内容，为空时不发给它们
+	legacy string
+	// retainKey 不为空时 hub 保留该消息作为最新状态
+	retainKey   string
+	retainClear bool
+
+	// 各编码的结果只计算一次，在订阅者间共享
+	jsonData    encodedOnce
+	msgpackData encodedOnce
+	cborData    encodedOnce
+}
+
+type encodedOnce struct {
+	once sync.Once
+	data []byte
+}
+
+// get 第一次调用时用 fn 编码，编码失败时记录日志并返回 nil
+func (e *encodedOnce) get(msgType, encoding string, fn func() ([]byte, error)) []byte {
+	e.once.Do(func() {
+		data, err := fn()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module":   "WebSocket",
+				"type":     msgType,
+				"encoding": encoding,
+				"error":    err,
+			}).Error("消息编码失败")
+			return
+		}
+		e.data = data
+	})
+	return e.data
+}
+
+// NewWeightMessage 创建重量消息；legacy 为旧版文本，非稳定毛重读数时为空
+func NewWeightMessage(scaleID string, weight float64, unit string, stable bool, status, legacy string, at time.Time) *Message {
+	return &Message{
+		V:         ProtocolVersion,
+		Type:      TypeWeight,
+		ScaleID:   scaleID,
+		Stream:    StreamWeights,
+		Weight:    &weight,
+		Unit:      unit,
+		Stable:    &stable,
+		Status:    status,
+		TS:        at.UnixMilli(),
+		topic:     TopicScale(scaleID),
+		legacy:    legacy,
+		retainKey: defaultRetainKey(TypeWeight, TopicScale(scaleID)),
+	}
+}
+
+// Topic 返回消息所属的订阅主题
+func (m *Message) Topic() string {
+	return m.topic
+}
+
+// NewEventMessage 创建状态、打印任务等事件消息，只发给 JSON 客户端
+func NewEventMessage(msgType, scaleID string, data interface{}) *Message {
+	topic := messageTopic(msgType, scaleID)
+	return &Message{
+		V:         ProtocolVersion,
+		Type:      msgType,
+		ScaleID:   scaleID,
+		Stream:    messageStream(msgType),
+		TS:        time.Now().UnixMilli(),
+		Data:      data,
+		topic:     topic,
+		retainKey: defaultRetainKey(msgType, topic),
+	}
+}
+
+// encode 按客户端编码返回要发送的内容，ok 为false表示该消息不发给此客户端
+func (m *Message) encode(encoding string) ([]byte, bool) {
+	if encoding == EncodingText {
+		return []byte(m.legacy), m.legacy != ""
+	}
+	if m.Type == "" {
+		return nil, false // Broadcast 发布的纯文本消息
+	}
+	var data []byte
+	switch encoding {
+	case EncodingMsgpack:
+		data = m.msgpackData.get(m.Type, encoding, func() ([]byte, error) {
+			return m.encodeBinary(marshalMsgpack)
+		})
+	case EncodingCBOR:
+		data = m.cborData.get(m.Type, encoding, func() ([]byte, error) {
+			return m.encodeBinary(marshalCBOR)
+		})
+	default:
+		data = m.jsonData.get(m.Type, EncodingJSON, func() ([]byte, error) {
+			return json.Marshal(m)
+		})
+	}
+	return data, data != nil
+}
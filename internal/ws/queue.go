@@ -0,0 +1,175 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 发送队列满时的处理策略。任何策略都只丢弃读数，事件和指令结果不会丢失：
+// 队列中没有可以丢弃的读数时断开连接，客户端重连后按事件序号续传。
+const (
+	PolicyDisconnect = "disconnect"  // 丢弃新读数，连续丢弃 MaxDrops 次后断开
+	PolicyDropOldest = "drop_oldest" // 丢弃最早的读数
+	PolicyConflate   = "conflate"    // 同一地磅的读数只保留最新一条，队列仍满时丢弃最早的读数
+)
+
+// Backpressure 是客户端处理不过来时的策略
+type Backpressure struct {
+	Policy     string // 默认 conflate
+	BufferSize int    // 发送队列长度，默认 10
+	MaxDrops   int    // disconnect 策略下连续丢弃多少条后断开，默认 10
+}
+
+func (b Backpressure) withDefaults() Backpressure {
+	if b.Policy == "" {
+		b.Policy = PolicyConflate
+	}
+	if b.BufferSize <= 0 {
+		b.BufferSize = 10
+	}
+	if b.MaxDrops <= 0 {
+		b.MaxDrops = 10
+	}
+	return b
+}
+
+// inherit 用 base 填充未设置的字段
+func (b Backpressure) inherit(base Backpressure) Backpressure {
+	if b.Policy == "" {
+		b.Policy = base.Policy
+	}
+	if b.BufferSize <= 0 {
+		b.BufferSize = base.BufferSize
+	}
+	if b.MaxDrops <= 0 {
+		b.MaxDrops = base.MaxDrops
+	}
+	return b
+}
+
+// Validate 检查策略名是否有效
+func (b Backpressure) Validate() error {
+	switch b.Policy {
+	case "", PolicyDisconnect, PolicyDropOldest, PolicyConflate:
+		return nil
+	}
+	return fmt.Errorf("未知的慢客户端策略: %s", b.Policy)
+}
+
+// conflateKey 返回可合并消息的 key，只有读数会被新读数取代
+func (m *Message) conflateKey() string {
+	if m.Type == TypeWeight {
+		return m.retainKey
+	}
+	return ""
+}
+
+// sendQueue 是单个客户端的发送队列，push 不会阻塞发布者
+type sendQueue struct {
+	bp     Backpressure
+	notify chan struct{} // 有新消息时非阻塞通知发送goroutine
+
+	mu          sync.Mutex
+	items       []*Message
+	drops       int64 // 因队列满丢弃的消息数
+	conflated   int64 // 被新读数取代的消息数
+	consecutive int   // 连续丢弃次数
+}
+
+func newSendQueue(bp Backpressure) *sendQueue {
+	bp = bp.withDefaults()
+	return &sendQueue{
+		bp:     bp,
+		notify: make(chan struct{}, 1),
+		items:  make([]*Message, 0, bp.BufferSize),
+	}
+}
+
+// push 按策略入队，返回false表示应断开该客户端
+func (q *sendQueue) push(msg *Message) bool {
+	q.mu.Lock()
+	ok := q.pushLocked(msg)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return ok
+}
+
+func (q *sendQueue) pushLocked(msg *Message) bool {
+	if q.bp.Policy == PolicyConflate {
+		if key := msg.conflateKey(); key != "" {
+			for i, queued := range q.items {
+				if queued.conflateKey() == key {
+					// 移除旧读数并把新读数排到队尾，保持消息顺序
+					copy(q.items[i:], q.items[i+1:])
+					q.items[len(q.items)-1] = msg
+					q.conflated++
+					return true
+				}
+			}
+		}
+	}
+
+	if len(q.items) < q.bp.BufferSize {
+		q.items = append(q.items, msg)
+		q.consecutive = 0
+		return true
+	}
+
+	q.drops++
+	if q.bp.Policy == PolicyDisconnect {
+		q.consecutive++
+		if q.consecutive >= q.bp.MaxDrops {
+			return false
+		}
+		if msg.Stream == StreamWeights {
+			return true
+		}
+		return q.evictWeight(msg)
+	}
+	if q.evictWeight(msg) {
+		return true
+	}
+	// 队列中全是事件：新读数可以丢弃，新事件只能断开
+	return msg.Stream == StreamWeights
+}
+
+// evictWeight 丢弃最早的一条读数并把 msg 排到队尾，队列中没有读数时返回 false
+func (q *sendQueue) evictWeight(msg *Message) bool {
+	for i, queued := range q.items {
+		if queued.Stream == StreamWeights {
+			copy(q.items[i:], q.items[i+1:])
+			q.items[len(q.items)-1] = msg
+			return true
+		}
+	}
+	return false
+}
+
+// pop 取出队列中的全部消息
+func (q *sendQueue) pop() []*Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	items := q.items
+	q.items = make([]*Message, 0, q.bp.BufferSize)
+	return items
+}
+
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// counters 返回丢弃和合并的消息数
+func (q *sendQueue) counters() (drops, conflated int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.drops, q.conflated
+}
@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func queued(q *sendQueue) []uint64 {
+	var seqs []uint64
+	for _, m := range q.pop() {
+		seqs = append(seqs, m.Seq)
+	}
+	return seqs
+}
+
+func msgWithSeq(m *Message, seq uint64) *Message {
+	m.Seq = seq
+	return m
+}
+
+func TestSendQueuePolicies(t *testing.T) {
+	weight := func(scaleID string, seq uint64) *Message {
+		return msgWithSeq(NewWeightMessage(scaleID, 1, "kg", true, "GS", "", time.Now()), seq)
+	}
+	event := func(seq uint64) *Message {
+		return msgWithSeq(NewEventMessage(TypePrintJob, "", nil), seq)
+	}
+
+	t.Run("disconnect", func(t *testing.T) {
+		q := newSendQueue(Backpressure{Policy: PolicyDisconnect, BufferSize: 2, MaxDrops: 2})
+		if !q.push(weight("a", 1)) || !q.push(weight("b", 2)) || !q.push(weight("a", 3)) {
+			t.Fatal("disconnected before MaxDrops consecutive drops")
+		}
+		if q.push(weight("a", 4)) {
+			t.Fatal("still connected after MaxDrops consecutive drops")
+		}
+		if got := queued(q); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+			t.Fatalf("queued = %v, want [1 2]", got)
+		}
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		q := newSendQueue(Backpressure{Policy: PolicyDropOldest, BufferSize: 2})
+		for i := uint64(1); i <= 4; i++ {
+			if !q.push(weight("a", i)) {
+				t.Fatal("drop_oldest should not disconnect while readings can be dropped")
+			}
+		}
+		if got := queued(q); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+			t.Fatalf("queued = %v, want [3 4]", got)
+		}
+		if drops, _ := q.counters(); drops != 2 {
+			t.Fatalf("drops = %d, want 2", drops)
+		}
+	})
+
+	t.Run("conflate", func(t *testing.T) {
+		q := newSendQueue(Backpressure{Policy: PolicyConflate, BufferSize: 3})
+		q.push(weight("a", 1))
+		q.push(event(2))
+		q.push(weight("b", 3))
+		q.push(weight("a", 4))
+		q.push(weight("a", 5))
+		if got := queued(q); len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 5 {
+			t.Fatalf("queued = %v, want [2 3 5]", got)
+		}
+		if drops, conflated := q.counters(); drops != 0 || conflated != 2 {
+			t.Fatalf("drops, conflated = %d, %d, want 0, 2", drops, conflated)
+		}
+	})
+	// 读数涌入时排在前面的事件不能被挤掉
+	for _, policy := range []string{PolicyConflate, PolicyDropOldest, PolicyDisconnect} {
+		t.Run(policy+"/keeps events", func(t *testing.T) {
+			q := newSendQueue(Backpressure{Policy: policy, BufferSize: 3, MaxDrops: 100})
+			q.push(event(1))
+			for i := uint64(2); i < 20; i++ {
+				if !q.push(weight(string(rune('a'+i)), i)) {
+					t.Fatalf("disconnected while readings could be dropped")
+				}
+			}
+			if got := queued(q); len(got) != 3 || got[0] != 1 {
+				t.Fatalf("queued = %v, want event 1 first", got)
+			}
+		})
+
+		t.Run(policy+"/only events", func(t *testing.T) {
+			q := newSendQueue(Backpressure{Policy: policy, BufferSize: 2, MaxDrops: 100})
+			q.push(event(1))
+			q.push(event(2))
+			if !q.push(weight("a", 3)) {
+				t.Fatal("disconnected for a reading that can be dropped")
+			}
+			if q.push(event(4)) {
+				t.Fatal("event dropped without disconnecting")
+			}
+			if got := queued(q); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+				t.Fatalf("queued = %v, want [1 2]", got)
+			}
+		})
+	}
+}
+
+func TestBackpressurePerEndpoint(t *testing.T) {
+	cfg := Config{}.withDefaults()
+	if cfg.Backpressure.Policy != PolicyConflate || cfg.SSEBackpressure.Policy != PolicyConflate {
+		t.Fatalf("default policies = %q, %q, want %q", cfg.Backpressure.Policy, cfg.SSEBackpressure.Policy, PolicyConflate)
+	}
+
+	cfg = Config{
+		Backpressure:    Backpressure{Policy: PolicyDisconnect, BufferSize: 4, MaxDrops: 3},
+		SSEBackpressure: Backpressure{Policy: PolicyDropOldest},
+	}.withDefaults()
+	want := Backpressure{Policy: PolicyDropOldest, BufferSize: 4, MaxDrops: 3}
+	if cfg.SSEBackpressure != want {
+		t.Fatalf("SSEBackpressure = %+v, want %+v", cfg.SSEBackpressure, want)
+	}
+	if cfg.Backpressure.Policy != PolicyDisconnect {
+		t.Fatalf("Backpressure = %+v", cfg.Backpressure)
+	}
+}
@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	errClosed         = errors.New("服务正在关闭")
+	errTooManyClients = errors.New("连接数已达上限")
+)
+
+// registry 是已连接客户端的登记表，写入时复制。
+// 推送只读取当前快照，不加锁；连接和断开只在复制时互斥，不会阻塞正在进行的推送。
+type registry struct {
+	mu      sync.Mutex // 串行化写入
+	clients atomic.Pointer[[]*client]
+	closed  bool // 关闭后不再登记新客户端，受 mu 保护
+}
+
+// load 返回当前快照，调用方不能修改
+func (r *registry) load() []*client {
+	if list := r.clients.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+func (r *registry) len() int {
+	return len(r.load())
+}
+
+// add 登记客户端，返回登记后的客户端数；登记表已关闭或已有 max 个客户端时返回错误，max 为 0 表示不限制
+func (r *registry) add(c *client, max int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.load()
+	if err := r.admitLocked(len(old), max); err != nil {
+		return len(old), err
+	}
+	list := make([]*client, len(old), len(old)+1)
+	copy(list, old)
+	list = append(list, c)
+	r.clients.Store(&list)
+	return len(list), nil
+}
+
+// admit 检查能否再登记一个客户端，用于在升级连接前拒绝
+func (r *registry) admit(max int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.admitLocked(r.len(), max)
+}
+
+func (r *registry) admitLocked(n, max int) error {
+	if r.closed {
+		return errClosed
+	}
+	if max > 0 && n >= max {
+		return errTooManyClients
+	}
+	return nil
+}
+
+// close 关闭登记表，返回此时登记的客户端
+func (r *registry) close() []*client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.load()
+}
+
+// remove 移除客户端，返回是否曾经登记和移除后的客户端数
+func (r *registry) remove(c *client) (bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.load()
+	for i, item := range old {
+		if item != c {
+			continue
+		}
+		list := make([]*client, 0, len(old)-1)
+		list = append(list, old[:i]...)
+		list = append(list, old[i+1:]...)
+		r.clients.Store(&list)
+		return true, len(list)
+	}
+	return false, len(old)
+}
@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// replayLog 保存最近发布的事件，断线重连的客户端可以从上次收到的序号续传。
+// 只追加不修改：add 返回新日志，旧日志仍然有效，可以作为状态快照的一部分不加锁读取。
+type replayLog struct {
+	size int
+	msgs []*Message
+}
+
+// add 返回追加 msg 后的日志。新元素写在所有旧日志的长度之外，不会改变旧日志看到的内容。
+func (l replayLog) add(msg *Message) replayLog {
+	if l.size <= 0 {
+		return l
+	}
+	msgs := append(l.msgs, msg)
+	if len(msgs) > l.size {
+		msgs = msgs[len(msgs)-l.size:]
+	}
+	return replayLog{size: l.size, msgs: msgs}
+}
+
+// since 返回序号大于 seq 的消息；seq 之后的消息已经被覆盖时 ok 为false
+func (l replayLog) since(seq uint64) (msgs []*Message, ok bool) {
+	if len(l.msgs) == 0 {
+		return nil, true
+	}
+	if seq+1 < l.msgs[0].Seq {
+		return nil, false
+	}
+	for _, msg := range l.msgs {
+		if msg.Seq > seq {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, true
+}
+
+// oldest 返回仍可补发的最早序号，日志为空时返回0
+func (l replayLog) oldest() uint64 {
+	if len(l.msgs) == 0 {
+		return 0
+	}
+	return l.msgs[0].Seq
+}
+
+// resyncInfo 是 resync 消息的内容
+type resyncInfo struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Since  uint64 `json:"since"`  // 客户端请求续传的序号
+	Oldest uint64 `json:"oldest"` // 服务端仍保留的最早序号
+	Latest uint64 `json:"latest"` // 服务端最新序号
+}
+
+// backlog 读取当前状态，返回新客户端在实时消息之前需要收到的消息，调用前客户端必须已经登记
+func (h *Hub) backlog(c *client, since uint64, resume bool) []*Message {
+	st := h.state.Load()
+	c.after = st.published
+	return st.backlog(c, since, resume)
+}
+
+// backlog 返回新客户端在实时消息之前需要收到的消息。
+// 未指定 since 时返回最新状态快照；能从 since 续传时返回各地磅最新读数和 since 之后的事件；
+// 无法续传时先发送 resync 消息，再发送完整快照。
+func (st *hubState) backlog(c *client, since uint64, resume bool) []*Message {
+	now := time.Now()
+	if !resume {
+		return st.snapshot(c, nil, now)
+	}
+
+	latest := st.seqs[StreamEvents]
+	missed, ok := st.replay.since(since)
+	if !ok || since > latest {
+		// since 比最新序号还大说明服务已经重启，序号重新开始
+		info := resyncInfo{Type: TypeResync, Reason: "gap too large, resync", Since: since, Oldest: st.replay.oldest(), Latest: latest}
+		resync := NewEventMessage(TypeResync, "", info)
+		if data, err := json.Marshal(info); err == nil {
+			resync.legacy = string(data)
+		}
+		return append([]*Message{resync}, st.snapshot(c, nil, now)...)
+	}
+
+	var out []*Message
+	for _, msg := range st.snapshot(c, nil, now) {
+		if msg.Stream == StreamWeights {
+			out = append(out, msg)
+		}
+	}
+	for _, msg := range missed {
+		if c.subs.matches(msg.topic) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package ws
+
+import (
+	"sort"
+	"time"
+)
+
+// Retain 让 hub 把该消息保留为 key 对应的最新状态，新客户端连接或订阅时立即收到。
+// 重量和地磅状态消息会自动保留，其他消息需要显式调用。
+func (m *Message) Retain(key string) *Message {
+	m.retainKey, m.retainClear = key, false
+	return m
+}
+
+// Release 表示 key 对应的状态已经结束（如打印任务完成），发布后删除保留的消息
+func (m *Message) Release(key string) *Message {
+	m.retainKey, m.retainClear = key, true
+	return m
+}
+
+// defaultRetainKey 返回自动保留的消息的 key
+func defaultRetainKey(msgType, topic string) string {
+	switch msgType {
+	case TypeWeight, TypeStatus:
+		return msgType + "/" + topic
+	}
+	return ""
+}
+
+// snapshot 返回匹配订阅的保留状态，topics 为空时按客户端当前订阅匹配
+func (st *hubState) snapshot(c *client, topics []string, now time.Time) []*Message {
+	var out []*Message
+	for _, msg := range st.retained {
+		if topics == nil {
+			if !c.subs.matches(msg.topic) {
+				continue
+			}
+		} else if !matchesAny(topics, msg.topic) {
+			continue
+		}
+		out = append(out, msg.snapshot(now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].order < out[j].order })
+	return out
+}
+
+func matchesAny(patterns []string, topic string) bool {
+	for _, p := range patterns {
+		if topicMatch(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot 复制一条保留的消息，标记为快照并附上距今的时间
+func (m *Message) snapshot(now time.Time) *Message {
+	return &Message{
+		V:        m.V,
+		Type:     m.Type,
+		ScaleID:  m.ScaleID,
+		Weight:   m.Weight,
+		Unit:     m.Unit,
+		Stable:   m.Stable,
+		Status:   m.Status,
+		TS:       m.TS,
+		Stream:   m.Stream,
+		Seq:      m.Seq,
+		Data:     m.Data,
+		Snapshot: true,
+		AgeMs:    now.UnixMilli() - m.TS,
+		order:    m.order,
+		topic:    m.topic,
+		legacy:   m.legacy,
+	}
+}
+
+// sendSnapshot 把新订阅的主题的保留状态发给客户端
+func (h *Hub) sendSnapshot(c *client, topics []string) {
+	msgs := h.state.Load().snapshot(c, topics, time.Now())
+	for _, msg := range msgs {
+		h.sendTo(c, msg)
+	}
+}
@@ -0,0 +1,169 @@
+package ws
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"reader/internal/auth"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lastEventID 读取 Last-Event-ID 头；EventSource 无法自定义请求头，也接受 ?since= 参数
+func lastEventID(r *http.Request) (uint64, bool) {
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		seq, err := strconv.ParseUint(v, 10, 64)
+		return seq, err == nil
+	}
+	return sinceParam(r)
+}
+
+// HandleSSE 处理 GET /events，以 Server-Sent Events 推送与 WebSocket 相同的 JSON 消息
+func (h *Hub) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if !h.admit(w, r, "SSE") {
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	c := &client{
+		id:          h.nextID.Add(1),
+		transport:   TransportSSE,
+		remote:      r.RemoteAddr,
+		userAgent:   r.UserAgent(),
+		identity:    auth.IdentityFrom(r.Context()),
+		encoding:    EncodingJSON,
+		subs:        newSubscriptions(requestTopics(r)),
+		send:        newSendQueue(h.cfg.SSEBackpressure),
+		throttle:    newThrottle(),
+		connectedAt: time.Now(),
+		closed:      make(chan struct{}),
+	}
+	c.touch()
+	h.setRate(c, requestRate(r))
+	lastSeq, resume := lastEventID(r)
+
+	clientCount, err := h.clients.add(c, h.cfg.MaxClients)
+	backlog := h.backlog(c, lastSeq, resume)
+	if err != nil {
+		// 检查之后服务开始关闭或名额被占满，此时还没有写响应头
+		h.reject(w, r, "SSE", err)
+		return
+	}
+
+	defer func() {
+		_, remainingCount := h.clients.remove(c)
+		logrus.WithFields(logrus.Fields{
+			"module":         "SSE",
+			"client":         c.id,
+			"reason":         c.closeReason,
+			"age":            time.Since(c.connectedAt).Round(time.Second),
+			"sent":           c.sent.Load(),
+			"remainingCount": remainingCount,
+		}).Info("客户端连接已断开")
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"module":      "SSE",
+		"client":      c.id,
+		"remote":      c.remote,
+		"identity":    c.identity.Name,
+		"topics":      c.subs.list(),
+		"resume":      resume,
+		"backlog":     len(backlog),
+		"clientCount": clientCount,
+	}).Info("新客户端连接")
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭反向代理缓冲
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	flush := func() bool {
+		rc.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+		if err := bw.Flush(); err != nil {
+			return false
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+		return true
+	}
+
+	for _, msg := range backlog {
+		h.writeEvent(bw, c, msg)
+	}
+	if !flush() {
+		return
+	}
+
+	var ping <-chan time.Time
+	if h.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(h.cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	throttled := time.NewTimer(time.Hour)
+	throttled.Stop()
+	defer throttled.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			c.close(0, "")
+			return
+		case <-c.closed:
+			// 告知客户端断开原因，EventSource 会自动重连并从 Last-Event-ID 续传
+			fmt.Fprintf(bw, "event: close\ndata: %q\n\n", c.closeReason)
+			flush()
+			return
+		case <-ping:
+			// 注释行用于保持代理和 NAT 连接
+			bw.WriteString(": ping\n\n")
+			if !flush() {
+				c.close(0, "")
+				return
+			}
+		case <-throttled.C:
+			now := time.Now()
+			for _, msg := range c.throttle.due(now) {
+				h.writeEvent(bw, c, msg)
+			}
+			c.throttle.resetTimer(throttled, now)
+			if !flush() {
+				c.close(0, "")
+				return
+			}
+		case <-c.send.notify:
+			now := time.Now()
+			for _, msg := range c.throttle.filter(c.pending(), now) {
+				h.writeEvent(bw, c, msg)
+			}
+			c.throttle.resetTimer(throttled, now)
+			if !flush() {
+				c.close(0, "")
+				return
+			}
+		}
+	}
+}
+
+// writeEvent 写入一条 SSE 事件。只有事件流的消息带 id，Last-Event-ID 即为事件序号；
+// 读数和快照不带 id，避免续传时把它们当作已收到的位置
+func (h *Hub) writeEvent(bw *bufio.Writer, c *client, msg *Message) {
+	data, ok := msg.encode(c.encoding)
+	if !ok {
+		return
+	}
+	if msg.Stream == StreamEvents && !msg.Snapshot {
+		fmt.Fprintf(bw, "id: %d\n", msg.Seq)
+	}
+	fmt.Fprintf(bw, "event: %s\ndata: %s\n\n", msg.Type, data)
+	c.sent.Add(1)
+}
@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents 读取 n 条 SSE 事件，返回每条事件的 id/event 行
+func readEvents(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var events []string
+	var current []string
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if len(current) > 0 {
+				events = append(events, strings.Join(current, " "))
+				current = nil
+			}
+		case strings.HasPrefix(line, "id: "), strings.HasPrefix(line, "event: "):
+			current = append(current, line)
+		}
+	}
+	return events
+}
+
+func openSSE(t *testing.T, url, lastID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestSSEResumeAndFilter(t *testing.T) {
+	h := NewHub(Config{ReplaySize: 4})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleSSE))
+	t.Cleanup(srv.Close) // 先于 openSSE 注册，客户端断开后才关闭服务
+
+	h.Publish(NewWeightMessage("a", 1, "kg", true, "GS", "", time.Now()))
+	for i := 0; i < 3; i++ {
+		h.Publish(NewEventMessage(TypeStatus, "a", nil))
+		h.Publish(NewEventMessage(TypeStatus, "b", nil))
+	}
+
+	// 从事件序号 3 续传，只要 scale:a：先收到最新读数（不带 id），再收到事件 5
+	r := openSSE(t, srv.URL+"?topics=scale:a", "3")
+	if got := readEvents(t, r, 2); got[0] != "event: weight" || got[1] != "id: 5 event: status" {
+		t.Fatalf("resumed events = %v", got)
+	}
+	waitClients(t, h, 1)
+	h.Publish(NewWeightMessage("a", 2, "kg", true, "GS", "", time.Now()))
+	h.Publish(NewEventMessage(TypePrintJob, "", nil))
+	h.Publish(NewEventMessage(TypeStatus, "a", nil))
+	if got := readEvents(t, r, 2); got[0] != "event: weight" || got[1] != "id: 8 event: status" {
+		t.Fatalf("live events = %v", got)
+	}
+
+	// 序号 1 之后的事件已被覆盖，先收到 resync，再收到最新状态快照
+	r = openSSE(t, srv.URL+"?topics=scale:b", "1")
+	if got := readEvents(t, r, 2); got[0] != "event: resync" || got[1] != "event: status" {
+		t.Fatalf("resync events = %v", got)
+	}
+}
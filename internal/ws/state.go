@@ -0,0 +1,48 @@
+package ws
+
+// hubState 是某条消息发布之后的序号、保留状态和续传日志，发布后不再修改。
+// Publish 每次生成新状态并原子替换，新连接和订阅只读取当前状态，不与推送争用锁。
+type hubState struct {
+	published uint64              // 最后一条消息的发布顺序
+	seqs      map[string]uint64   // 各流的最新序号
+	retained  map[string]*Message // 各主题的最新状态，新连接时补发
+	replay    replayLog           // 最近发布的事件，断线续传时补发
+}
+
+func newHubState(replaySize int) *hubState {
+	return &hubState{
+		seqs:     make(map[string]uint64),
+		retained: make(map[string]*Message),
+		replay:   replayLog{size: replaySize},
+	}
+}
+
+// next 给 msg 分配发布顺序和序号，返回包含它的新状态；只能由持有发布锁的 Publish 调用
+func (st *hubState) next(msg *Message) *hubState {
+	next := *st
+	next.published++
+	msg.order = next.published
+	if msg.Stream != "" {
+		next.seqs = make(map[string]uint64, len(st.seqs)+1)
+		for stream, seq := range st.seqs {
+			next.seqs[stream] = seq
+		}
+		next.seqs[msg.Stream]++
+		msg.Seq = next.seqs[msg.Stream]
+	}
+	if msg.retainKey != "" {
+		next.retained = make(map[string]*Message, len(st.retained)+1)
+		for key, retained := range st.retained {
+			next.retained[key] = retained
+		}
+		if msg.retainClear {
+			delete(next.retained, msg.retainKey)
+		} else {
+			next.retained[msg.retainKey] = msg
+		}
+	}
+	if msg.Stream == StreamEvents {
+		next.replay = st.replay.add(msg)
+	}
+	return &next
+}
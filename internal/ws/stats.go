@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClientStats 是单个 WebSocket 连接的运行情况，不含远程地址等客户端信息，这些只在管理接口中返回
+type ClientStats struct {
+	ID          uint64    `json:"id"`
+	Transport   string    `json:"transport"`
+	Encoding    string    `json:"encoding"`
+	Rate        float64   `json:"rate"` // 每台地磅每秒最多推送的读数，0 表示不限制
+	ConnectedAt time.Time `json:"connectedAt"`
+	AgeSeconds  float64   `json:"ageSeconds"`  // 连接时长
+	IdleSeconds float64   `json:"idleSeconds"` // 距最近一次收到客户端消息或 pong 的时间
+	Sent        int64     `json:"sent"`
+	Frames      int64     `json:"frames"`    // WebSocket 帧数，合并发送时少于 sent
+	Queued      int       `json:"queued"`    // 发送队列中待发的消息数
+	Drops       int64     `json:"drops"`     // 因队列满丢弃的消息数
+	Conflated   int64     `json:"conflated"` // 被新读数取代的消息数
+}
+
+// Stats 是 WebSocket 推送的整体运行情况
+type Stats struct {
+	Clients        int           `json:"clients"`
+	MaxClients     int           `json:"maxClients"`      // 连接数上限，0 表示不限制
+	Policy         string        `json:"policy"`          // WebSocket 慢客户端策略
+	SSEPolicy      string        `json:"ssePolicy"`       // SSE 慢客户端策略
+	SlowDisconnect int64         `json:"slowDisconnects"` // 因处理过慢被断开的次数
+	PongTimeouts   int64         `json:"pongTimeouts"`    // 因心跳超时被断开的次数
+	Rejected       int64         `json:"rejected"`        // 因连接数已达上限被拒绝的次数
+	MaxAgeSeconds  float64       `json:"maxAgeSeconds"`   // 最老连接的时长
+	MaxIdleSeconds float64       `json:"maxIdleSeconds"`  // 最久没有响应的连接的静默时间
+	Connections    []ClientStats `json:"connections"`
+}
+
+func (c *client) stats(now time.Time) ClientStats {
+	drops, conflated := c.send.counters()
+	return ClientStats{
+		ID:          c.id,
+		Transport:   c.transport,
+		Encoding:    c.encoding,
+		Rate:        c.throttle.rate(),
+		ConnectedAt: c.connectedAt,
+		AgeSeconds:  now.Sub(c.connectedAt).Seconds(),
+		IdleSeconds: now.Sub(time.Unix(0, c.lastSeen.Load())).Seconds(),
+		Sent:        c.sent.Load(),
+		Frames:      c.frames.Load(),
+		Queued:      c.send.len(),
+		Drops:       drops,
+		Conflated:   conflated,
+	}
+}
+
+// Stats 返回当前连接的时长、静默时间等指标
+func (h *Hub) Stats() Stats {
+	now := time.Now()
+	clients := h.clients.load()
+	st := Stats{
+		Clients:        len(clients),
+		MaxClients:     h.cfg.MaxClients,
+		Policy:         h.cfg.Backpressure.Policy,
+		SSEPolicy:      h.cfg.SSEBackpressure.Policy,
+		SlowDisconnect: h.dropped.Load(),
+		PongTimeouts:   h.timeouts.Load(),
+		Rejected:       h.rejected.Load(),
+		Connections:    make([]ClientStats, 0, len(clients)),
+	}
+	for _, c := range clients {
+		cs := c.stats(now)
+		if cs.AgeSeconds > st.MaxAgeSeconds {
+			st.MaxAgeSeconds = cs.AgeSeconds
+		}
+		if cs.IdleSeconds > st.MaxIdleSeconds {
+			st.MaxIdleSeconds = cs.IdleSeconds
+		}
+		st.Connections = append(st.Connections, cs)
+	}
+
+	sort.Slice(st.Connections, func(i, j int) bool { return st.Connections[i].ID < st.Connections[j].ID })
+	return st
+}
+
+// StatsHandler 处理 GET /ws/stats
+func (h *Hub) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Stats()); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "WebSocket",
+			"error":  err,
+		}).Error("响应编码失败")
+	}
+}
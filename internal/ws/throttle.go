@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// throttle 按客户端要求的最高频率限制读数推送。同一地磅在间隔内的读数只保留最新一条，
+// 到期后补发；事件不受限制。只在发送goroutine中使用，interval 除外。
+type throttle struct {
+	interval atomic.Int64 // 纳秒，0 表示不限制
+	last     map[string]time.Time
+	pending  map[string]*Message
+}
+
+func newThrottle() *throttle {
+	return &throttle{last: make(map[string]time.Time), pending: make(map[string]*Message)}
+}
+
+// rateInterval 把客户端请求的频率（次/秒）按服务端上限换算成发送间隔，0 表示不限制
+func rateInterval(requested, ceiling float64) time.Duration {
+	rate := requested
+	if !(rate > 0) { // 包括 NaN
+		rate = 0
+	}
+	if ceiling > 0 && (rate == 0 || rate > ceiling) {
+		rate = ceiling
+	}
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// rate 返回当前生效的频率，0 表示不限制
+func (t *throttle) rate() float64 {
+	if d := t.interval.Load(); d > 0 {
+		return float64(time.Second) / float64(d)
+	}
+	return 0
+}
+
+// filter 返回现在可以发送的消息，过快的读数暂存到 pending
+func (t *throttle) filter(msgs []*Message, now time.Time) []*Message {
+	interval := time.Duration(t.interval.Load())
+	if interval <= 0 && len(t.pending) == 0 {
+		return msgs
+	}
+	out := msgs[:0]
+	for _, msg := range msgs {
+		key := msg.conflateKey()
+		if key == "" || msg.Snapshot {
+			out = append(out, msg)
+			continue
+		}
+		if now.Sub(t.last[key]) < interval {
+			t.pending[key] = msg
+			continue
+		}
+		t.last[key] = now
+		delete(t.pending, key)
+		out = append(out, msg)
+	}
+	return out
+}
+
+// due 取出已经到期的暂存读数
+func (t *throttle) due(now time.Time) []*Message {
+	interval := time.Duration(t.interval.Load())
+	var out []*Message
+	for key, msg := range t.pending {
+		if now.Sub(t.last[key]) >= interval {
+			t.last[key] = now
+			delete(t.pending, key)
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// wait 返回距离下一条暂存读数到期的时间，没有暂存读数时返回 ok=false
+func (t *throttle) wait(now time.Time) (d time.Duration, ok bool) {
+	interval := time.Duration(t.interval.Load())
+	for key := range t.pending {
+		remaining := t.last[key].Add(interval).Sub(now)
+		if !ok || remaining < d {
+			d, ok = remaining, true
+		}
+	}
+	if ok && d < 0 {
+		d = 0
+	}
+	return d, ok
+}
+
+// resetTimer 按暂存读数重新设置定时器
+func (t *throttle) resetTimer(timer *time.Timer, now time.Time) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	if d, ok := t.wait(now); ok {
+		timer.Reset(d)
+	}
+}
+
+// requestRate 读取 ?rate= 参数（次/秒）
+func requestRate(r *http.Request) float64 {
+	rate, err := strconv.ParseFloat(r.URL.Query().Get("rate"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
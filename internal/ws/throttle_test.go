@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateInterval(t *testing.T) {
+	cases := []struct {
+		requested, ceiling float64
+		want               time.Duration
+	}{
+		{0, 0, 0},
+		{5, 0, 200 * time.Millisecond},
+		{0, 2, 500 * time.Millisecond},  // 不限制时按上限
+		{10, 2, 500 * time.Millisecond}, // 超过上限时按上限
+		{1, 2, time.Second},
+		{-1, 0, 0},
+	}
+	for _, c := range cases {
+		if got := rateInterval(c.requested, c.ceiling); got != c.want {
+			t.Errorf("rateInterval(%v, %v) = %v, want %v", c.requested, c.ceiling, got, c.want)
+		}
+	}
+}
+
+func TestThrottleConflatesWeights(t *testing.T) {
+	th := newThrottle()
+	th.interval.Store(int64(time.Second))
+	start := time.Now()
+	weight := func(scaleID string, v float64) *Message {
+		return NewWeightMessage(scaleID, v, "kg", true, "GS", "", start)
+	}
+
+	if got := th.filter([]*Message{weight("a", 1), weight("b", 1)}, start); len(got) != 2 {
+		t.Fatalf("first readings sent = %d, want 2", len(got))
+	}
+	// 间隔内的读数只保留最新一条，事件照常发送
+	now := start.Add(100 * time.Millisecond)
+	got := th.filter([]*Message{weight("a", 2), NewEventMessage(TypeStatus, "a", nil), weight("a", 3)}, now)
+	if len(got) != 1 || got[0].Type != TypeStatus {
+		t.Fatalf("sent within interval = %d messages", len(got))
+	}
+	if d, ok := th.wait(now); !ok || d != 900*time.Millisecond {
+		t.Fatalf("wait = %v, %v", d, ok)
+	}
+	if due := th.due(now); len(due) != 0 {
+		t.Fatalf("due early = %d", len(due))
+	}
+	due := th.due(start.Add(time.Second))
+	if len(due) != 1 || *due[0].Weight != 3 {
+		t.Fatalf("due = %d messages", len(due))
+	}
+	if _, ok := th.wait(start.Add(time.Second)); ok {
+		t.Fatal("pending readings left after due")
+	}
+}
@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 订阅主题
+const (
+	TopicAll      = "*"
+	TopicPrint    = "print:jobs"
+	topicScalePre = "scale:"
+)
+
+// TopicScale 返回某台地磅读数和状态的主题，如 scale:entry
+func TopicScale(scaleID string) string {
+	return topicScalePre + scaleID
+}
+
+// messageTopic 返回消息所属主题，指令结果等单发消息没有主题
+func messageTopic(msgType, scaleID string) string {
+	switch msgType {
+	case TypeWeight, TypeStatus:
+		return TopicScale(scaleID)
+	case TypePrintJob:
+		return TopicPrint
+	}
+	return ""
+}
+
+// topicMatch 判断订阅模式是否匹配主题，支持 * 和 scale:* 这样的前缀通配
+func topicMatch(pattern, topic string) bool {
+	if pattern == TopicAll || pattern == topic {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return false
+}
+
+// subscriptions 是一个客户端订阅的主题。未指定主题的客户端默认订阅全部，
+// 第一次显式订阅会替换这个默认订阅。
+type subscriptions struct {
+	mu       sync.RWMutex
+	topics   map[string]struct{}
+	implicit bool
+}
+
+func newSubscriptions(topics []string) *subscriptions {
+	s := &subscriptions{topics: make(map[string]struct{})}
+	if len(topics) == 0 {
+		s.topics[TopicAll] = struct{}{}
+		s.implicit = true
+	}
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+	return s
+}
+
+func (s *subscriptions) matches(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for pattern := range s.topics {
+		if topicMatch(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe 添加主题；主题列表为空时不做任何修改，以免丢掉默认的全部订阅
+func (s *subscriptions) subscribe(topics []string) {
+	if len(topics) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.implicit {
+		delete(s.topics, TopicAll)
+		s.implicit = false
+	}
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+}
+
+func (s *subscriptions) unsubscribe(topics []string) {
+	if len(topics) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.implicit = false
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+func (s *subscriptions) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// requestTopics 解析 ?topics=a,b 和重复的 ?topic= 参数
+func requestTopics(r *http.Request) []string {
+	query := r.URL.Query()
+	var topics []string
+	for _, v := range query["topics"] {
+		topics = append(topics, splitTopics(v)...)
+	}
+	for _, v := range query["topic"] {
+		topics = append(topics, splitTopics(v)...)
+	}
+	return topics
+}
+
+func splitTopics(v string) []string {
+	var topics []string
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
@@ -1,32 +1,231 @@
 package ws
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"reader/internal/auth"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// 服务端主动断开时关闭帧的原因
+const (
+	reasonSlowClient  = "slow client"
+	reasonPongTimeout = "pong timeout"
+	reasonShutdown    = "server shutting down"
+	reasonTooMany     = "too many clients"
+	reasonAdmin       = "disconnected by admin"
+)
+
+// Config 是 WebSocket 连接参数，PongTimeout 和 WriteTimeout 为零时使用默认值
+type Config struct {
+	PingInterval time.Duration // 心跳 ping 间隔，0 表示不发送心跳，也不设置读超时
+	PongTimeout  time.Duration // ping 之后等待 pong 的最长时间
+	WriteTimeout time.Duration // 单条消息写入的最长时间
+	Backpressure Backpressure  // WebSocket 客户端处理不过来时的策略
+	// SSE 客户端处理不过来时的策略，未设置的字段沿用 Backpressure
+	SSEBackpressure Backpressure
+	ReplaySize      int     // 保留最近多少条消息用于断线续传，默认 256
+	MaxRate         float64 // 每台地磅每秒最多推送几条读数，0 表示不限制；客户端请求的频率不能超过它
+	MaxClients      int     // WebSocket 和 SSE 连接总数上限，0 表示不限制
+
+	// 客户端支持时启用 permessage-deflate，只压缩不小于 CompressionThreshold 字节的消息
+	Compression          bool
+	CompressionLevel     int // flate 压缩级别 1（最快）到 9（最小），默认 1
+	CompressionThreshold int // 字节，默认 256
+}
+
+func (c Config) withDefaults() Config {
+	if c.PongTimeout <= 0 {
+		c.PongTimeout = 10 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+	c.SSEBackpressure = c.SSEBackpressure.inherit(c.Backpressure).withDefaults()
+	c.Backpressure = c.Backpressure.withDefaults()
+	if c.ReplaySize <= 0 {
+		c.ReplaySize = 256
+	}
+	if c.CompressionLevel < 1 || c.CompressionLevel > 9 {
+		c.CompressionLevel = 1
+	}
+	if c.CompressionThreshold <= 0 {
+		c.CompressionThreshold = 256
+	}
+	return c
+}
+
+// 客户端的连接方式
+const (
+	TransportWS  = "websocket"
+	TransportSSE = "sse"
+)
+
+// client 是一个 WebSocket 或 SSE 连接及其发送队列
+type client struct {
+	id          uint64
+	transport   string
+	conn        *websocket.Conn // 仅 WebSocket 连接
+	remote      string
+	userAgent   string
+	identity    auth.Identity
+	encoding    string
+	subs        *subscriptions
+	send        *sendQueue
+	throttle    *throttle
+	connectedAt time.Time
+	lastSeen    atomic.Int64 // UnixNano，最近一次收到客户端消息或 pong
+	sent        atomic.Int64
+	frames      atomic.Int64 // 合并发送时少于 sent
+	batch       bool         // 客户端能够解析数组帧
+	after       uint64       // 连接时快照的发布顺序，只由发送goroutine使用
+
+	closeOnce   sync.Once
+	closed      chan struct{}
+	closeCode   int // 0 表示不发送关闭帧
+	closeReason string
+}
+
+// close 通知发送goroutine带着关闭帧断开连接，只有第一次调用生效
+func (c *client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.closed)
+	})
+}
+
+// pending 取出发送队列中的消息，跳过发布顺序不晚于连接时快照的消息：它们已经包含在补发内容中
+func (c *client) pending() []*Message {
+	msgs := c.send.pop()
+	if c.after == 0 {
+		return msgs
+	}
+	out := msgs[:0]
+	for _, msg := range msgs {
+		if msg.order == 0 || msg.Snapshot || msg.order > c.after {
+			out = append(out, msg)
+		}
+	}
+	if len(out) > 0 && out[len(out)-1].order > c.after {
+		// 之后发布的消息顺序都更大，不必再检查
+		c.after = 0
+	}
+	return out
+}
+
+func (c *client) touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
 }
 
 type Hub struct {
-	clients map[*websocket.Conn]chan string
-	lock    sync.RWMutex
+	cfg       Config
+	upgrader  websocket.Upgrader
+	clients   registry
+	state     atomic.Pointer[hubState] // 序号、保留状态和续传日志，只由 Publish 替换
+	publish   sync.Mutex               // 串行化发布，保证每个客户端按序号顺序收到消息
+	lock      sync.RWMutex             // 保护 onCommand
+	onCommand CommandFunc
+	nextID    atomic.Uint64
+	dropped   atomic.Int64 // 因处理过慢被断开的客户端数
+	timeouts  atomic.Int64 // 因心跳超时被断开的客户端数
+	rejected  atomic.Int64 // 因连接数已达上限被拒绝的次数
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		clients: make(map[*websocket.Conn]chan string),
+func NewHub(cfg Config) *Hub {
+	cfg = cfg.withDefaults()
+	h := &Hub{
+		cfg: cfg,
+		// 来源由 auth 中间件在升级前检查
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       func(r *http.Request) bool { return true },
+			Subprotocols:      []string{SubprotocolJSON, SubprotocolMsgpack, SubprotocolCBOR},
+			EnableCompression: cfg.Compression,
+		},
 	}
+	h.state.Store(newHubState(cfg.ReplaySize))
+	return h
 }
 
-func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+// sinceParam 读取 ?since=<seq>，即客户端最后收到的事件序号
+func sinceParam(r *http.Request) (uint64, bool) {
+	seq, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	return seq, err == nil
+}
+
+// requestEncoding 根据协商出的子协议或 ?format= 参数选择消息编码，默认旧版文本
+func requestEncoding(r *http.Request, conn *websocket.Conn) string {
+	switch conn.Subprotocol() {
+	case SubprotocolJSON:
+		return EncodingJSON
+	case SubprotocolMsgpack:
+		return EncodingMsgpack
+	case SubprotocolCBOR:
+		return EncodingCBOR
+	}
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case EncodingJSON, EncodingMsgpack, EncodingCBOR:
+		return format
+	}
+	return EncodingText
+}
+
+// admit 在升级连接前检查能否接受新客户端，不能时返回 503 并说明原因
+func (h *Hub) admit(w http.ResponseWriter, r *http.Request, module string) bool {
+	err := h.clients.admit(h.cfg.MaxClients)
+	if err == nil {
+		return true
+	}
+	h.reject(w, r, module, err)
+	return false
+}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+func (h *Hub) reject(w http.ResponseWriter, r *http.Request, module string, err error) {
+	if errors.Is(err, errTooManyClients) {
+		h.rejectTooMany(r, module)
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, fmt.Sprintf("连接数已达上限（%d），请稍后重试", h.cfg.MaxClients), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+func (h *Hub) rejectTooMany(r *http.Request, module string) {
+	h.rejected.Add(1)
+	logrus.WithFields(logrus.Fields{
+		"module":     module,
+		"remote":     r.RemoteAddr,
+		"maxClients": h.cfg.MaxClients,
+	}).Warn("连接数已达上限，拒绝新连接")
+}
+
+// refuse 发送关闭帧后断开升级后才登记失败的 WebSocket 连接：检查和登记之间服务开始关闭，或其他连接抢先占满了名额
+func (h *Hub) refuse(conn *websocket.Conn, r *http.Request, module string, err error) {
+	code, reason := websocket.CloseGoingAway, reasonShutdown
+	if errors.Is(err, errTooManyClients) {
+		h.rejectTooMany(r, module)
+		code, reason = websocket.CloseTryAgainLater, reasonTooMany
+	}
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(h.cfg.WriteTimeout))
+	conn.Close()
+}
+
+func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
+	if !h.admit(w, r, "WebSocket") {
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"module": "WebSocket",
@@ -34,125 +233,302 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 		}).Error("连接升级失败")
 		return
 	}
+	conn.SetCompressionLevel(h.cfg.CompressionLevel)
 
-	ch := make(chan string, 10)
-	h.lock.Lock()
-	h.clients[conn] = ch
-	clientCount := len(h.clients)
-	h.lock.Unlock()
+	c := &client{
+		id:          h.nextID.Add(1),
+		transport:   TransportWS,
+		conn:        conn,
+		remote:      r.RemoteAddr,
+		userAgent:   r.UserAgent(),
+		identity:    auth.IdentityFrom(r.Context()),
+		encoding:    requestEncoding(r, conn),
+		batch:       requestBatch(r),
+		subs:        newSubscriptions(requestTopics(r)),
+		send:        newSendQueue(h.cfg.Backpressure),
+		throttle:    newThrottle(),
+		connectedAt: time.Now(),
+		closed:      make(chan struct{}),
+	}
+	c.touch()
+	h.setRate(c, requestRate(r))
+	clientCount, err := h.clients.add(c, h.cfg.MaxClients)
+	if err != nil {
+		h.refuse(conn, r, "WebSocket", err)
+		return
+	}
+	since, resume := sinceParam(r)
+	backlog := h.backlog(c, since, resume)
 
 	logrus.WithFields(logrus.Fields{
 		"module":      "WebSocket",
+		"client":      c.id,
+		"remote":      c.remote,
+		"identity":    c.identity.Name,
+		"encoding":    c.encoding,
+		"topics":      c.subs.list(),
 		"clientCount": clientCount,
 	}).Info("新客户端连接")
 
-	// 用于通知发送goroutine退出
-	done := make(chan struct{})
+	go h.readLoop(c)
+	go h.writeLoop(c, backlog)
+}
 
-	// 读消息goroutine，及时检测客户端断开
-	go func() {
-		defer close(done)
-		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
+// readDeadline 是两次心跳之间允许客户端静默的最长时间
+func (h *Hub) readDeadline() time.Time {
+	if h.cfg.PingInterval <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(h.cfg.PingInterval + h.cfg.PongTimeout)
+}
+
+// readLoop 及时检测客户端断开，并处理客户端发来的订阅和指令
+func (h *Hub) readLoop(c *client) {
+	conn := c.conn
+	conn.SetReadDeadline(h.readDeadline())
+	conn.SetPongHandler(func(string) error {
+		c.touch()
+		return conn.SetReadDeadline(h.readDeadline())
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				h.timeouts.Add(1)
 				logrus.WithFields(logrus.Fields{
 					"module": "WebSocket",
-					"error":  err,
-				}).Debug("检测到客户端断开")
+					"client": c.id,
+					"remote": c.remote,
+					"idle":   time.Since(time.Unix(0, c.lastSeen.Load())).Round(time.Millisecond),
+				}).Warn("客户端心跳超时，断开连接")
+				c.close(websocket.ClosePolicyViolation, reasonPongTimeout)
 				return
 			}
+			logrus.WithFields(logrus.Fields{
+				"module": "WebSocket",
+				"client": c.id,
+				"error":  err,
+			}).Debug("检测到客户端断开")
+			c.close(0, "")
+			return
+		}
+		c.touch()
+		conn.SetReadDeadline(h.readDeadline())
+		h.handleClientMessage(c, data)
+	}
+}
+
+// writeLoop 是唯一写连接的goroutine，先发送快照或续传的消息，再推送消息、发送心跳和关闭帧
+func (h *Hub) writeLoop(c *client, backlog []*Message) {
+	conn := c.conn
+	var ping <-chan time.Time
+	if h.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(h.cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	throttled := time.NewTimer(time.Hour)
+	throttled.Stop()
+	defer throttled.Stop()
+
+	defer func() {
+		_, remainingCount := h.clients.remove(c)
+
+		if c.closeCode != 0 {
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(h.cfg.WriteTimeout))
 		}
+		if err := conn.Close(); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"module": "WebSocket",
+				"error":  err,
+			}).Warn("关闭连接时出错")
+		}
+		logrus.WithFields(logrus.Fields{
+			"module":         "WebSocket",
+			"client":         c.id,
+			"reason":         c.closeReason,
+			"age":            time.Since(c.connectedAt).Round(time.Second),
+			"sent":           c.sent.Load(),
+			"remainingCount": remainingCount,
+		}).Info("客户端连接已断开")
 	}()
 
-	// 发送消息goroutine
-	go func() {
-		defer func() {
-			h.lock.Lock()
-			close(ch)
-			delete(h.clients, conn)
-			remainingCount := len(h.clients)
-			h.lock.Unlock()
+	if !h.write(c, backlog) {
+		return
+	}
 
-			if err := conn.Close(); err != nil {
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ping:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.cfg.WriteTimeout)); err != nil {
 				logrus.WithFields(logrus.Fields{
 					"module": "WebSocket",
+					"client": c.id,
 					"error":  err,
-				}).Warn("关闭连接时出错")
+				}).Debug("发送心跳失败")
+				c.close(0, "")
+				return
 			}
-			logrus.WithFields(logrus.Fields{
-				"module":         "WebSocket",
-				"remainingCount": remainingCount,
-			}).Info("客户端连接已断开")
-		}()
-
-		for {
-			select {
-			case msg, ok := <-ch:
-				if !ok {
-					return
-				}
-				if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
-					logrus.WithFields(logrus.Fields{
-						"module": "WebSocket",
-						"error":  err,
-					}).Error("写入消息失败")
-					return
-				}
-			case <-done:
+		case <-throttled.C:
+			now := time.Now()
+			if !h.write(c, c.throttle.due(now)) {
+				return
+			}
+			c.throttle.resetTimer(throttled, now)
+		case <-c.send.notify:
+			now := time.Now()
+			if !h.write(c, c.throttle.filter(c.pending(), now)) {
 				return
 			}
+			c.throttle.resetTimer(throttled, now)
 		}
-	}()
+	}
+}
+
+// setRate 设置客户端的读数推送频率，不超过服务端上限，返回生效的频率
+func (h *Hub) setRate(c *client, requested float64) float64 {
+	c.throttle.interval.Store(int64(rateInterval(requested, h.cfg.MaxRate)))
+	return c.throttle.rate()
+}
+
+// write 按客户端编码写入消息，客户端要求合并时多条消息合并为一帧，写入失败时返回false
+func (h *Hub) write(c *client, msgs []*Message) bool {
+	payloads := make([][]byte, 0, len(msgs))
+	for _, msg := range msgs {
+		if data, ok := msg.encode(c.encoding); ok {
+			payloads = append(payloads, data)
+		}
+	}
+	if !c.batch || c.encoding == EncodingText {
+		for _, data := range payloads {
+			if !h.writeFrame(c, data, 1) {
+				return false
+			}
+		}
+		return true
+	}
+	for len(payloads) > 0 {
+		n := len(payloads)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		data := payloads[0]
+		if n > 1 {
+			data = batchFrame(c.encoding, payloads[:n])
+		}
+		if !h.writeFrame(c, data, int64(n)) {
+			return false
+		}
+		payloads = payloads[n:]
+	}
+	return true
+}
+
+// writeFrame 写入一帧，其中包含 n 条消息
+func (h *Hub) writeFrame(c *client, data []byte, n int64) bool {
+	frame := websocket.TextMessage
+	if binaryEncoding(c.encoding) {
+		frame = websocket.BinaryMessage
+	}
+	// 未协商压缩时不生效
+	c.conn.EnableWriteCompression(len(data) >= h.cfg.CompressionThreshold)
+	c.conn.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+	if err := c.conn.WriteMessage(frame, data); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "WebSocket",
+			"client": c.id,
+			"error":  err,
+		}).Error("写入消息失败")
+		c.close(0, "")
+		return false
+	}
+	c.sent.Add(n)
+	c.frames.Add(1)
+	return true
 }
 
 func (h *Hub) GetClientCount() int {
-	h.lock.RLock()
-	defer h.lock.RUnlock()
-	return len(h.clients)
+	return h.clients.len()
 }
 
+// Broadcast 向旧版文本客户端推送一条消息，JSON 客户端不会收到
 func (h *Hub) Broadcast(msg string) {
-	h.lock.Lock()
-	defer h.lock.Unlock()
+	h.Publish(&Message{V: ProtocolVersion, legacy: msg})
+}
+
+// Publish 在消息所属流内分配序号后推送给订阅了该主题的客户端，每个客户端按自己的编码发送
+func (h *Hub) Publish(msg *Message) {
+	h.publish.Lock()
+	defer h.publish.Unlock()
 
-	if len(h.clients) == 0 {
+	// 先替换状态再读取客户端列表；新连接先登记再读取状态（见 backlog）。
+	// 两边至少有一边看到对方：这条消息要么进入新连接的发送队列，要么包含在它的快照中，两边都有时由 client.pending 去重
+	h.state.Store(h.state.Load().next(msg))
+	clients := h.clients.load()
+	if len(clients) == 0 {
 		return // 没有客户端连接，直接返回
 	}
 
-	var closedClients []*websocket.Conn
+	var closedClients []*client
 
-	for conn, ch := range h.clients {
-		select {
-		case ch <- msg:
-			// 成功发送
-		default:
-			// 缓冲区满或连接已关闭，标记为需要关闭的客户端
-			closedClients = append(closedClients, conn)
+	for _, c := range clients {
+		if msg.topic != "" && !c.subs.matches(msg.topic) {
+			continue
+		}
+		if !c.send.push(msg) {
+			// 按策略需要断开的客户端
+			closedClients = append(closedClients, c)
 		}
 	}
 
-	// 批量处理需要关闭的客户端，避免在锁内进行网络操作
-	for _, conn := range closedClients {
-		if ch, exists := h.clients[conn]; exists {
-			logrus.WithField("module", "WebSocket").Warn("客户端连接异常，移除连接")
-			close(ch)
-			delete(h.clients, conn)
-			// 异步关闭连接，避免阻塞
-			go func(c *websocket.Conn) {
-				if err := c.Close(); err != nil {
-					logrus.WithFields(logrus.Fields{
-						"module": "WebSocket",
-						"error":  err,
-					}).Warn("关闭异常客户端连接时出错")
-				}
-			}(conn)
-		}
+	// 发送goroutine负责发送关闭帧并关闭连接，这里只移除登记
+	remaining := len(clients)
+	for _, c := range closedClients {
+		h.dropSlow(c)
+		remaining = h.clients.len()
 	}
 
 	if len(closedClients) > 0 {
 		logrus.WithFields(logrus.Fields{
 			"module":       "WebSocket",
 			"removedCount": len(closedClients),
-			"currentCount": len(h.clients),
-		}).Warn("移除异常客户端")
+			"currentCount": remaining,
+		}).Warn("移除处理过慢的客户端")
+	}
+}
+
+// Shutdown 不再接受新连接，向所有客户端发送 1001 关闭帧，等待它们断开或 ctx 到期
+func (h *Hub) Shutdown(ctx context.Context) error {
+	clients := h.clients.close()
+	for _, c := range clients {
+		c.close(websocket.CloseGoingAway, reasonShutdown)
+	}
+	logrus.WithFields(logrus.Fields{
+		"module":      "WebSocket",
+		"clientCount": len(clients),
+	}).Info("正在断开所有客户端")
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for h.clients.len() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// dropSlow 移除按策略需要断开的客户端
+func (h *Hub) dropSlow(c *client) {
+	if removed, _ := h.clients.remove(c); removed {
+		h.dropped.Add(1)
 	}
+	c.close(websocket.ClosePolicyViolation, reasonSlowClient)
 }
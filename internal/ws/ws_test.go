@@ -0,0 +1,371 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dial(t *testing.T, url string, subprotocols ...string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitClients(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for h.GetClientCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetClientCount() = %d, want %d", h.GetClientCount(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+func TestHubEncodings(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	legacy := dial(t, srv.URL)
+	byQuery := dial(t, srv.URL+"?format=json")
+	byProtocol := dial(t, srv.URL, SubprotocolJSON)
+	if byProtocol.Subprotocol() != SubprotocolJSON {
+		t.Fatalf("Subprotocol() = %q", byProtocol.Subprotocol())
+	}
+	waitClients(t, h, 3)
+
+	// 事件只发给 JSON 客户端，旧版客户端应直接收到下一条重量文本
+	h.Publish(NewEventMessage(TypeStatus, "a", map[string]string{"state": "connected"}))
+	h.Publish(NewWeightMessage("a", 12.5, "kg", true, "GS", "ST,GS    12.5kg\r\n", time.UnixMilli(1000)))
+
+	if got := readText(t, legacy); got != "ST,GS    12.5kg\r\n" {
+		t.Fatalf("legacy client got %q", got)
+	}
+	for _, conn := range []*websocket.Conn{byQuery, byProtocol} {
+		var status, weight Message
+		json.Unmarshal([]byte(readText(t, conn)), &status)
+		json.Unmarshal([]byte(readText(t, conn)), &weight)
+		if status.Type != TypeStatus || status.Stream != StreamEvents || status.Seq != 1 {
+			t.Fatalf("status message = %+v", &status)
+		}
+		if weight.V != ProtocolVersion || weight.Type != TypeWeight || weight.ScaleID != "a" || weight.Stream != StreamWeights || weight.Seq != 1 ||
+			weight.Weight == nil || *weight.Weight != 12.5 || weight.Stable == nil || !*weight.Stable ||
+			weight.Status != "GS" || weight.TS != 1000 {
+			t.Fatalf("weight message = %+v", &weight)
+		}
+	}
+}
+
+func TestHubTopics(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL+"?format=json&topics=scale:a")
+	waitClients(t, h, 1)
+
+	h.Publish(NewWeightMessage("b", 1, "kg", true, "GS", "", time.Now()))
+	h.Publish(NewWeightMessage("a", 2, "kg", true, "GS", "", time.Now()))
+	var msg Message
+	json.Unmarshal([]byte(readText(t, conn)), &msg)
+	if msg.ScaleID != "a" {
+		t.Fatalf("got message for scale %q, want only scale a", msg.ScaleID)
+	}
+
+	conn.WriteJSON(map[string]interface{}{"type": "subscribe", "id": "s1", "topics": []string{TopicPrint}})
+	var reply struct {
+		Type string             `json:"type"`
+		Data subscriptionsReply `json:"data"`
+	}
+	json.Unmarshal([]byte(readText(t, conn)), &reply)
+	if reply.Type != TypeSubscriptions || reply.Data.ID != "s1" || strings.Join(reply.Data.Topics, ",") != "print:jobs,scale:a" {
+		t.Fatalf("subscribe reply = %+v", reply)
+	}
+
+	conn.WriteJSON(map[string]interface{}{"type": "unsubscribe", "topics": []string{"scale:a"}})
+	readText(t, conn)
+	h.Publish(NewWeightMessage("a", 3, "kg", true, "GS", "", time.Now()))
+	h.Publish(NewEventMessage(TypePrintJob, "", nil))
+	json.Unmarshal([]byte(readText(t, conn)), &msg)
+	if msg.Type != TypePrintJob {
+		t.Fatalf("got %q after unsubscribing scale:a, want print_job", msg.Type)
+	}
+}
+
+func TestTopicMatch(t *testing.T) {
+	cases := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"*", "print:jobs", true},
+		{"scale:*", "scale:entry", true},
+		{"scale:*", "print:jobs", false},
+		{"scale:entry", "scale:exit", false},
+	}
+	for _, c := range cases {
+		if got := topicMatch(c.pattern, c.topic); got != c.want {
+			t.Errorf("topicMatch(%q, %q) = %v, want %v", c.pattern, c.topic, got, c.want)
+		}
+	}
+}
+
+func TestSubscribeWithoutTopics(t *testing.T) {
+	s := newSubscriptions(nil)
+	s.subscribe(nil)
+	s.subscribe([]string{})
+	s.unsubscribe(nil)
+	if !s.matches(TopicPrint) || strings.Join(s.list(), ",") != TopicAll {
+		t.Fatalf("subscriptions after empty subscribe = %v, want [*]", s.list())
+	}
+	// 之后的显式订阅仍然替换默认订阅
+	s.subscribe([]string{"scale:a"})
+	if s.matches(TopicPrint) {
+		t.Fatalf("subscriptions = %v, want only scale:a", s.list())
+	}
+}
+
+func TestHubPongTimeout(t *testing.T) {
+	h := NewHub(Config{PingInterval: 20 * time.Millisecond, PongTimeout: 20 * time.Millisecond})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	// 不读取连接就不会回复 pong，模拟静默消失的客户端
+	conn.SetPingHandler(func(string) error { return nil })
+	waitClients(t, h, 1)
+	waitClients(t, h, 0)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reasonPongTimeout {
+		t.Fatalf("ReadMessage() error = %v, want close %d %q", err, websocket.ClosePolicyViolation, reasonPongTimeout)
+	}
+	if st := h.Stats(); st.PongTimeouts != 1 {
+		t.Fatalf("Stats().PongTimeouts = %d, want 1", st.PongTimeouts)
+	}
+}
+
+func TestHubShutdown(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	waitClients(t, h, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("ReadMessage() error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+
+	// 关闭后拒绝新连接
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dial after Shutdown: err = %v, resp = %v", err, resp)
+	}
+}
+
+func TestHubStats(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL+"?format=json")
+	waitClients(t, h, 1)
+	h.Publish(NewEventMessage(TypeStatus, "a", nil))
+	readText(t, conn)
+
+	st := h.Stats()
+	if st.Clients != 1 || len(st.Connections) != 1 {
+		t.Fatalf("Stats() = %+v", st)
+	}
+	c := st.Connections[0]
+	if c.Encoding != EncodingJSON || c.Sent != 1 || c.AgeSeconds <= 0 {
+		t.Fatalf("connection stats = %+v", c)
+	}
+}
+
+func TestHubSnapshotOnConnect(t *testing.T) {
+	h := NewHub(Config{})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	h.Publish(NewEventMessage(TypeStatus, "a", map[string]string{"state": "connected"}))
+	h.Publish(NewWeightMessage("a", 1, "kg", true, "GS", "old", time.Now()))
+	h.Publish(NewWeightMessage("a", 2, "kg", true, "GS", "ST,GS     2kg\r\n", time.Now().Add(-time.Second)))
+	h.Publish(NewEventMessage(TypePrintJob, "", "job1").Retain("print:jobs/1"))
+	h.Publish(NewEventMessage(TypePrintJob, "", "job2").Retain("print:jobs/2"))
+	h.Publish(NewEventMessage(TypePrintJob, "", "job1 done").Release("print:jobs/1"))
+
+	legacy := dial(t, srv.URL)
+	if got := readText(t, legacy); got != "ST,GS     2kg\r\n" {
+		t.Fatalf("legacy snapshot = %q", got)
+	}
+
+	conn := dial(t, srv.URL+"?format=json")
+	var got []string
+	for i := 0; i < 3; i++ {
+		var msg Message
+		json.Unmarshal([]byte(readText(t, conn)), &msg)
+		if !msg.Snapshot {
+			t.Fatalf("message %d is not marked as snapshot", i)
+		}
+		if msg.Type == TypeWeight && (*msg.Weight != 2 || msg.AgeMs < 1000) {
+			t.Fatalf("weight snapshot = %+v", &msg)
+		}
+		got = append(got, msg.Type)
+	}
+	if strings.Join(got, ",") != "status,weight,print_job" {
+		t.Fatalf("snapshot types = %v", got)
+	}
+}
+
+func TestHubResumeSince(t *testing.T) {
+	h := NewHub(Config{ReplaySize: 8})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		h.Publish(NewEventMessage(TypePrintJob, "", i))
+	}
+
+	conn := dial(t, srv.URL+"?format=json&since=1")
+	for _, want := range []uint64{2, 3} {
+		var msg Message
+		json.Unmarshal([]byte(readText(t, conn)), &msg)
+		if msg.Type != TypePrintJob || msg.Seq != want {
+			t.Fatalf("resumed message = %+v, want print_job seq %d", &msg, want)
+		}
+	}
+
+	// 服务重启后序号重新开始，客户端带着更大的序号重连时需要重新同步
+	conn = dial(t, srv.URL+"?format=json&since=99")
+	var msg struct {
+		Type string     `json:"type"`
+		Data resyncInfo `json:"data"`
+	}
+	json.Unmarshal([]byte(readText(t, conn)), &msg)
+	if msg.Type != TypeResync || msg.Data.Since != 99 || msg.Data.Latest != 3 {
+		t.Fatalf("resync message = %+v", msg)
+	}
+}
+
+func TestHubBatchAndCompression(t *testing.T) {
+	h := NewHub(Config{Compression: true, CompressionThreshold: 16})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+	for i := 0; i < 3; i++ {
+		h.Publish(NewEventMessage(TypeStatus, "a", map[string]int{"n": i}))
+	}
+
+	// 续传补发的 3 条事件合并为一帧
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?format=json&batch=true&since=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("Sec-WebSocket-Extensions = %q", ext)
+	}
+	var batch []Message
+	if err := json.Unmarshal([]byte(readText(t, conn)), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 3 || batch[0].Seq != 1 || batch[2].Seq != 3 {
+		t.Fatalf("batch = %d messages", len(batch))
+	}
+	if st := h.Stats().Connections[0]; st.Sent != 3 || st.Frames != 1 {
+		t.Fatalf("sent = %d, frames = %d", st.Sent, st.Frames)
+	}
+
+	// 单条消息不包装为数组
+	h.Publish(NewEventMessage(TypeStatus, "a", nil))
+	var single Message
+	if err := json.Unmarshal([]byte(readText(t, conn)), &single); err != nil || single.Seq != 4 {
+		t.Fatalf("single message seq = %d, err = %v", single.Seq, err)
+	}
+}
+
+func TestBatchFrame(t *testing.T) {
+	payloads := [][]byte{{'1'}, {'2'}}
+	if got := string(batchFrame(EncodingJSON, payloads)); got != "[1,2]" {
+		t.Fatalf("json batch = %s", got)
+	}
+	// 二进制数组帧可以用标准库解码
+	msgs := []*Message{NewEventMessage(TypeStatus, "a", nil), NewEventMessage(TypePrintJob, "", "x")}
+	for _, encoding := range []string{EncodingMsgpack, EncodingCBOR} {
+		payloads = payloads[:0]
+		want := make([]interface{}, len(msgs))
+		for i, msg := range msgs {
+			data, _ := msg.encode(encoding)
+			payloads = append(payloads, data)
+			want[i] = jsonEnvelope(t, msg)
+		}
+		if got := decodeBinary(t, encoding, batchFrame(encoding, payloads)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s batch = %v, want %v", encoding, got, want)
+		}
+	}
+}
+
+func TestCommandRequiresAdmin(t *testing.T) {
+	h := NewHub(Config{})
+	called := make(chan struct{}, 1)
+	h.SetCommandHandler(func(ctx context.Context, scaleID, command string) (interface{}, error) {
+		called <- struct{}{}
+		return nil, nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWS))
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	waitClients(t, h, 1)
+	if err := conn.WriteJSON(clientMessage{Type: "command", ID: "1", ScaleID: "a", Command: "zero"}); err != nil {
+		t.Fatal(err)
+	}
+	var reply commandReply
+	if err := json.Unmarshal([]byte(readText(t, conn)), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Success || reply.Message != errForbidden.Error() || reply.ID != "1" {
+		t.Fatalf("anonymous command reply = %+v", reply)
+	}
+	select {
+	case <-called:
+		t.Fatal("anonymous command was executed")
+	default:
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"syscall"
 	"time"
 
+	"reader/internal/auth"
 	"reader/internal/config"
+	"reader/internal/display"
 	"reader/internal/print"
+	"reader/internal/scale"
 	"reader/internal/serial"
+	"reader/internal/share"
 	"reader/internal/ws"
 
 	"github.com/gorilla/mux"
@@ -84,6 +88,27 @@ func startMockDataGenerator(ctx context.Context, cfg *config.Config, callback fu
 	}()
 }
 
+// weightMessage 把地磅读数转换为 WebSocket 消息
+func weightMessage(u serial.Update) *ws.Message {
+	return ws.NewWeightMessage(u.ScaleID, u.Reading.Value(), u.Reading.Unit, u.Reading.Stable, u.Reading.Mode, u.Legacy, u.At)
+}
+
+// forwardEvents 把地磅的状态事件转发给 WebSocket 客户端
+func forwardEvents(ctx context.Context, manager *serial.SerialManager, hub *ws.Hub) {
+	events, unsubscribe := manager.SubscribeEvents(16)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				hub.Publish(ws.NewEventMessage(ws.TypeStatus, event.ScaleID, event))
+			}
+		}
+	}()
+}
+
 func main() {
 	initLogger()
 	cfg := config.LoadConfig()
@@ -105,69 +130,281 @@ func main() {
 		fmt.Printf("配置信息 - WebSocket端口: %d, 模拟消息数: %d, 推送间隔: %dms\n",
 			cfg.WebsocketPort, len(cfg.MockMessages), cfg.BroadcastInterval)
 	} else {
-		fmt.Printf("配置信息 - 串口: %s, 波特率: %d, WebSocket端口: %d, 推送间隔: %dms\n",
-			cfg.SerialPort, cfg.BaudRate, cfg.WebsocketPort, cfg.BroadcastInterval)
+		fmt.Printf("配置信息 - WebSocket端口: %d, 推送间隔: %dms\n", cfg.WebsocketPort, cfg.BroadcastInterval)
+		for _, sc := range cfg.ScaleConfigs() {
+			fmt.Printf("地磅 %s - 串口: %s, 波特率: %d, 型号: %s\n", sc.ID, sc.SerialPort, sc.BaudRate, sc.ScaleModel)
+		}
+	}
+
+	backpressure := ws.Backpressure{
+		Policy:     cfg.WS.SlowPolicy,
+		BufferSize: cfg.WS.BufferSize,
+		MaxDrops:   cfg.WS.MaxDrops,
+	}
+	if err := backpressure.Validate(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Error("WebSocket 配置无效，使用默认策略")
+		backpressure.Policy = ""
 	}
+	sseBackpressure := ws.Backpressure{
+		Policy:     cfg.WS.SSE.SlowPolicy,
+		BufferSize: cfg.WS.SSE.BufferSize,
+		MaxDrops:   cfg.WS.SSE.MaxDrops,
+	}
+	if err := sseBackpressure.Validate(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Error("SSE 配置无效，沿用 WebSocket 的策略")
+		sseBackpressure.Policy = ""
+	}
+	hub := ws.NewHub(ws.Config{
+		PingInterval:    time.Duration(cfg.WS.PingInterval) * time.Millisecond,
+		PongTimeout:     time.Duration(cfg.WS.PongTimeout) * time.Millisecond,
+		WriteTimeout:    time.Duration(cfg.WS.WriteTimeout) * time.Millisecond,
+		Backpressure:    backpressure,
+		SSEBackpressure: sseBackpressure,
+		ReplaySize:      cfg.WS.ReplaySize,
+		MaxRate:         cfg.WS.MaxRate,
+		MaxClients:      cfg.WS.MaxClients,
 
-	hub := ws.NewHub()
-	dataCallback := func(msg string) {
+		Compression:          cfg.WS.Compression,
+		CompressionLevel:     cfg.WS.CompressionLevel,
+		CompressionThreshold: cfg.WS.CompressionThreshold,
+	})
+	dataCallback := func(u serial.Update) {
 		logrus.WithFields(logrus.Fields{
 			"module": "MAIN",
-			"data":   msg,
+			"scale":  u.ScaleID,
+			"data":   u.Legacy,
+			"weight": u.Reading.Weight,
 		}).Debug("推送消息")
-		hub.Broadcast(msg)
+		hub.Publish(weightMessage(u))
+	}
+	print.SetJobListener(func(job print.Job) {
+		// 进行中的任务保留给新连接的客户端，结束后删除
+		msg := ws.NewEventMessage(ws.TypePrintJob, "", job)
+		key := ws.TopicPrint + "/" + job.ID
+		if job.State == print.JobCompleted || job.State == print.JobFailed {
+			msg.Release(key)
+		} else {
+			msg.Retain(key)
+		}
+		hub.Publish(msg)
+	})
+
+	if n, err := print.ResumePending(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Error("恢复保存的打印任务失败")
+	} else if n > 0 {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"count":  n,
+		}).Info("已恢复上次关闭时保存的打印任务")
 	}
 
+	tokens := make([]auth.Token, 0, len(cfg.Tokens))
+	for _, t := range cfg.Tokens {
+		tokens = append(tokens, auth.Token{Name: t.Name, Token: t.Token, Scopes: t.Scopes})
+	}
+	policy, err := auth.NewPolicy(auth.Config{
+		AllowedOrigins: cfg.AllowedOrigins,
+		Tokens:         tokens,
+		AdminToken:     cfg.AdminToken,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Fatal("访问控制配置无效")
+	}
+	if policy.AnyOrigin() {
+		logrus.WithField("module", "MAIN").Warn("未配置 allowed_origins，任意网页都可以访问本服务")
+	}
+
+	scales := serial.NewRegistry(time.Duration(cfg.CommandTimeout) * time.Millisecond)
+	hub.SetCommandHandler(func(ctx context.Context, scaleID, command string) (interface{}, error) {
+		return scales.SendCommand(ctx, scaleID, command)
+	})
+
 	// 用于控制模拟数据生成器的上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var manager *serial.SerialManager
 	if cfg.MockMode {
 		// 启动模拟数据生成器
-		startMockDataGenerator(ctx, cfg, dataCallback)
+		startMockDataGenerator(ctx, cfg, func(msg string) {
+			reading, err := scale.ParseReading(scale.ModelDefault, msg)
+			if err != nil {
+				// 无法解析的模拟报文只推送给旧版客户端
+				hub.Broadcast(msg)
+				return
+			}
+			dataCallback(serial.Update{ScaleID: config.DefaultScaleID, Reading: reading, At: time.Now(), Legacy: msg})
+		})
 		logrus.WithField("module", "MAIN").Info("模拟数据生成器已启动")
 	} else {
-		// 启动串口管理器
-		manager = serial.NewSerialManager(cfg.SerialPort, cfg.BaudRate, cfg.ScaleModel,
-			time.Duration(cfg.BroadcastInterval)*time.Millisecond, dataCallback)
-		manager.Start()
-		defer manager.Stop()
+		// 每台地磅启动一个串口管理器
+		for _, sc := range cfg.ScaleConfigs() {
+			manager := serial.NewSerialManager(sc.ID, serial.Settings{
+				PortName:        sc.SerialPort,
+				BaudRate:        sc.BaudRate,
+				Model:           sc.ScaleModel,
+				USB:             serial.USBMatch{VID: sc.USBVID, PID: sc.USBPID, SerialNumber: sc.USBSerial},
+				WatchdogTimeout: time.Duration(sc.WatchdogTimeout) * time.Millisecond,
+				HotplugInterval: time.Duration(cfg.HotplugInterval) * time.Millisecond,
+			}, time.Duration(cfg.BroadcastInterval)*time.Millisecond, dataCallback)
+			scales.Add(manager)
+			forwardEvents(ctx, manager, hub)
+			if err := manager.Start(); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"module": "MAIN",
+					"scale":  sc.ID,
+					"error":  err,
+				}).Error("地磅启动失败")
+				continue
+			}
+			defer manager.Stop()
+
+			if sc.Share != nil {
+				server, err := share.NewServer(manager, share.Config{
+					Port:       sc.Share.Port,
+					Mode:       sc.Share.Mode,
+					Format:     sc.Share.Format,
+					MaxClients: sc.Share.MaxClients,
+				})
+				if err == nil {
+					err = server.Start()
+				}
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"module": "MAIN",
+						"scale":  sc.ID,
+						"error":  err,
+					}).Error("串口共享服务启动失败")
+				} else {
+					defer server.Close()
+				}
+			}
+
+			if sc.Display != nil {
+				sink, err := display.NewSink(manager, display.Config{
+					Target:   sc.Display.Target,
+					BaudRate: sc.Display.BaudRate,
+					Protocol: sc.Display.Protocol,
+					Width:    sc.Display.Width,
+					Pad:      sc.Display.Pad,
+					Interval: time.Duration(sc.Display.Interval) * time.Millisecond,
+				})
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"module": "MAIN",
+						"scale":  sc.ID,
+						"error":  err,
+					}).Error("大屏输出配置无效")
+					continue
+				}
+				sink.Start()
+				defer sink.Close()
+			}
+		}
 	}
 
+	addr := fmt.Sprintf(":%d", cfg.WebsocketPort)
+	r := mux.NewRouter()
+	r.HandleFunc("/ws", policy.Require(auth.ScopeRead, hub.HandleWS))
+	r.HandleFunc("/events", policy.Require(auth.ScopeRead, hub.HandleSSE)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/ws/stats", policy.Require(auth.ScopeRead, hub.StatsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/ws/debug", policy.RequireAdmin(scales.DebugHandler))
+	r.HandleFunc("/admin/clients", policy.RequireAdmin(hub.ClientsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/admin/clients/{id}", policy.RequireAdmin(hub.DisconnectHandler)).Methods(http.MethodDelete, http.MethodOptions)
+	r.HandleFunc("/print", policy.Require(auth.ScopePrint, print.PrintHandler)).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/scales", policy.Require(auth.ScopeRead, scales.ListHandler)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/scales/{id}/weight", policy.Require(auth.ScopeRead, scales.WeightHandler)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/scales/{id}/status", policy.Require(auth.ScopeRead, scales.StatusHandler)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/scales/{id}/settings", policy.RequireAdmin(scales.SettingsHandler)).Methods(http.MethodPut, http.MethodOptions)
+	r.HandleFunc("/scales/{id}/commands", policy.RequireAdmin(scales.CommandHandler)).Methods(http.MethodPost, http.MethodOptions)
+
+	r.Use(mux.CORSMethodMiddleware(r))
+
+	logrus.WithFields(logrus.Fields{
+		"module":  "MAIN",
+		"address": addr,
+		"mode":    modeStr,
+	}).Info("地磅读取服务已启动")
+
+	srv := &http.Server{Addr: addr, Handler: r}
+
 	// 设置优雅关闭信号处理
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
 
 	go func() {
+		defer close(stopped)
 		<-c
 		logrus.WithField("module", "MAIN").Info("收到关闭信号，正在清理资源...")
 		fmt.Println("收到关闭信号，正在清理资源...")
 
+		shutdown(srv, hub, time.Duration(cfg.ShutdownTimeout)*time.Millisecond)
+
 		if cfg.MockMode {
 			cancel() // 停止模拟数据生成器
-		} else if manager != nil {
-			manager.Stop()
+		} else {
+			for _, manager := range scales.All() {
+				if err := manager.Stop(); err != nil {
+					logrus.WithFields(logrus.Fields{
+						"module": "MAIN",
+						"scale":  manager.ID(),
+						"error":  err,
+					}).Warn("关闭地磅时出错")
+				}
+			}
 		}
-
-		logrus.WithField("module", "MAIN").Info("资源清理完成，程序退出")
-		fmt.Println("资源清理完成，程序退出")
-		os.Exit(0)
 	}()
 
-	addr := fmt.Sprintf(":%d", cfg.WebsocketPort)
-	r := mux.NewRouter()
-	r.HandleFunc("/ws", hub.HandleWS)
-	r.HandleFunc("/print", print.PrintHandler).Methods(http.MethodPost, http.MethodOptions)
+	fmt.Printf("地磅读取服务已启动，运行在 http://localhost%s (%s)\n", addr, modeStr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		logrus.Fatal(err)
+	}
+	<-stopped
 
-	r.Use(mux.CORSMethodMiddleware(r))
+	// 返回后依次执行 defer，关闭大屏输出和串口共享
+	logrus.WithField("module", "MAIN").Info("资源清理完成，程序退出")
+	fmt.Println("资源清理完成，程序退出")
+}
 
-	logrus.WithFields(logrus.Fields{
-		"module":  "MAIN",
-		"address": addr,
-		"mode":    modeStr,
-	}).Info("地磅读取服务已启动")
+// shutdown 停止接受新连接，以 1001 关闭帧断开 WebSocket 和 SSE 客户端，等待打印队列完成或保存剩余任务，
+// 全部在 timeout 内完成
+func shutdown(srv *http.Server, hub *ws.Hub, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	fmt.Printf("地磅读取服务已启动，运行在 http://localhost%s (%s)\n", addr, modeStr)
-	logrus.Fatal(http.ListenAndServe(addr, r))
+	// Shutdown 立即关闭监听，再等待进行中的请求；SSE 和打印请求在下面两步之后才会结束
+	served := make(chan error, 1)
+	go func() { served <- srv.Shutdown(ctx) }()
+
+	if err := hub.Shutdown(ctx); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Warn("部分客户端未能及时断开")
+	}
+	if err := print.Shutdown(ctx); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Warn("打印队列未能及时完成")
+	}
+	if err := <-served; err != nil {
+		logrus.WithFields(logrus.Fields{
+			"module": "MAIN",
+			"error":  err,
+		}).Warn("HTTP 服务未能及时关闭，强制断开剩余连接")
+		srv.Close()
+	}
 }